@@ -0,0 +1,131 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/tormgibbs/snapluks-backend/internal/data"
+	"github.com/tormgibbs/snapluks-backend/internal/validator"
+)
+
+func (app *application) createAppointmentGroupHandler(w http.ResponseWriter, r *http.Request) {
+	user := app.contextGetUser(r)
+
+	if user.Role != data.RoleClient {
+		app.notPermittedResponse(w, r)
+		return
+	}
+
+	var input struct {
+		StartTime time.Time        `json:"start_time"`
+		Items     []data.GroupItem `json:"items"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	group := &data.AppointmentGroup{
+		ClientID:  user.ID,
+		StartTime: input.StartTime,
+	}
+
+	v := validator.New()
+
+	v.Check(!input.StartTime.IsZero(), "start_time", "must be provided")
+	v.Check(input.StartTime.After(time.Now()), "start_time", "must be in the future")
+
+	if data.ValidateGroupItems(v, input.Items); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.AppointmentGroups.Insert(group, input.Items)
+	if err != nil {
+		var itemErr *data.GroupItemError
+		if !errors.As(err, &itemErr) {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		key := func(field string) string {
+			return fmt.Sprintf("items[%d].%s", itemErr.Index, field)
+		}
+
+		switch {
+		case errors.Is(err, data.ErrStaffServiceMismatch):
+			v.AddError(key("staff_id"), "the selected staff member does not offer this service at this provider")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, data.ErrServiceNotFound):
+			v.AddError(key("service_id"), "service not found")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, data.ErrOutsideBusinessHours):
+			v.AddError(key("service_id"), "falls outside the provider's business hours")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, data.ErrStaffUnavailable):
+			v.AddError(key("staff_id"), "the selected staff member is not working at this time")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, data.ErrSlotUnavailable):
+			app.slotUnavailableResponse(w, r)
+		case errors.Is(err, data.ErrResourceUnavailable):
+			app.resourceUnavailableResponse(w, r)
+		case errors.Is(err, data.ErrSessionFull):
+			app.sessionFullResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"group": group}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) showAppointmentGroupHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	group, err := app.models.AppointmentGroups.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	user := app.contextGetUser(r)
+
+	ok, err := app.canAccessAppointment(user, &data.Appointment{ProviderID: group.ProviderID, ClientID: group.ClientID})
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if !ok {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	group.Appointments, err = app.models.Appointments.GetAllForGroup(group.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"group": group}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
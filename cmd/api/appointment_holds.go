@@ -0,0 +1,192 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/tormgibbs/snapluks-backend/internal/data"
+	"github.com/tormgibbs/snapluks-backend/internal/validator"
+)
+
+const (
+	// holdTTL is how long a client has to confirm a held slot.
+	holdTTL = 5 * time.Minute
+
+	// holdSweepInterval is how often expired holds are released. Bookings
+	// release them as well, so an expired hold never blocks a booking in
+	// the meantime, but it stays off the availability until it is swept.
+	holdSweepInterval = 30 * time.Second
+)
+
+// sweepExpiredHolds starts releasing expired holds every holdSweepInterval.
+func (app *application) sweepExpiredHolds() {
+	app.every(holdSweepInterval, func() {
+		released, err := app.models.Appointments.ReleaseExpiredHolds()
+		if err != nil {
+			app.logger.PrintError(err, nil)
+			return
+		}
+
+		if released > 0 {
+			app.logger.PrintInfo("released expired holds", map[string]string{
+				"count": fmt.Sprint(released),
+			})
+		}
+	})
+}
+
+// createAppointmentHoldHandler holds a slot for the client while they check
+// out. The hold takes the slot like a booking would and is released unless it
+// is confirmed within holdTTL.
+func (app *application) createAppointmentHoldHandler(w http.ResponseWriter, r *http.Request) {
+	user := app.contextGetUser(r)
+
+	if user.Role != data.RoleClient {
+		app.notPermittedResponse(w, r)
+		return
+	}
+
+	var input struct {
+		ServiceID int64     `json:"service_id"`
+		StaffID   int64     `json:"staff_id"`
+		StartTime time.Time `json:"start_time"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	appointment := &data.Appointment{
+		ServiceID: input.ServiceID,
+		StaffID:   input.StaffID,
+		ClientID:  user.ID,
+		StartTime: input.StartTime,
+	}
+
+	v := validator.New()
+
+	if data.ValidateAppointment(v, appointment); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Appointments.Hold(appointment, holdTTL)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrStaffServiceMismatch):
+			v.AddError("staff_id", "the selected staff member does not offer this service")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, data.ErrServiceNotFound):
+			v.AddError("service_id", "service not found")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, data.ErrOutsideBusinessHours):
+			v.AddError("start_time", "falls outside the provider's business hours")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, data.ErrStaffUnavailable):
+			v.AddError("start_time", "the selected staff member is not working at this time")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, data.ErrSlotUnavailable):
+			app.slotUnavailableResponse(w, r)
+		case errors.Is(err, data.ErrResourceUnavailable):
+			app.resourceUnavailableResponse(w, r)
+		case errors.Is(err, data.ErrSessionFull):
+			app.sessionFullResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"appointment": appointment}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) confirmAppointmentHoldHandler(w http.ResponseWriter, r *http.Request) {
+	appointment, ok := app.heldAppointment(w, r)
+	if !ok {
+		return
+	}
+
+	err := app.models.Appointments.ConfirmHold(appointment)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		case errors.Is(err, data.ErrHoldExpired):
+			app.holdExpiredResponse(w, r)
+		case errors.Is(err, data.ErrInvalidTransition):
+			v := validator.New()
+			v.AddError("status", fmt.Sprintf("cannot confirm a %s appointment", appointment.Status))
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"appointment": appointment}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// releaseAppointmentHoldHandler gives up a hold before it expires, freeing the
+// slot for others straight away.
+func (app *application) releaseAppointmentHoldHandler(w http.ResponseWriter, r *http.Request) {
+	appointment, ok := app.heldAppointment(w, r)
+	if !ok {
+		return
+	}
+
+	err := app.models.Appointments.ReleaseHold(appointment.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "hold successfully released"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// heldAppointment reads the appointment named in the URL and checks that it
+// was booked by the current user. Only the client who holds a slot can
+// confirm or release it. It writes the error response and returns false
+// otherwise.
+func (app *application) heldAppointment(w http.ResponseWriter, r *http.Request) (*data.Appointment, bool) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return nil, false
+	}
+
+	appointment, err := app.models.Appointments.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return nil, false
+	}
+
+	if appointment.ClientID != app.contextGetUser(r).ID {
+		app.notFoundResponse(w, r)
+		return nil, false
+	}
+
+	return appointment, true
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/tormgibbs/snapluks-backend/internal/data"
+	"github.com/tormgibbs/snapluks-backend/internal/validator"
+)
+
+const (
+	applyToThis      = "this"
+	applyToFollowing = "following"
+)
+
+// validateApplyTo checks whether a change is made to this occurrence only, the
+// default, or to this and the following occurrences of a recurring series.
+func validateApplyTo(v *validator.Validator, applyTo string, a *data.Appointment) {
+	v.Check(validator.In(applyTo, "", applyToThis, applyToFollowing), "apply_to", "must be either this or following")
+
+	if applyTo == applyToFollowing {
+		v.Check(a.SeriesID != nil, "apply_to", "the appointment is not part of a recurring series")
+	}
+}
+
+func (app *application) createAppointmentSeriesHandler(w http.ResponseWriter, r *http.Request) {
+	user := app.contextGetUser(r)
+
+	if user.Role != data.RoleClient {
+		app.notPermittedResponse(w, r)
+		return
+	}
+
+	var input struct {
+		ServiceID  int64               `json:"service_id"`
+		StaffID    int64               `json:"staff_id"`
+		StartTime  time.Time           `json:"start_time"`
+		Recurrence data.RecurrenceRule `json:"recurrence"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	series := &data.AppointmentSeries{
+		ServiceID:  input.ServiceID,
+		StaffID:    input.StaffID,
+		ClientID:   user.ID,
+		StartTime:  input.StartTime,
+		Recurrence: input.Recurrence,
+	}
+
+	v := validator.New()
+
+	data.ValidateAppointment(v, &data.Appointment{
+		ServiceID: series.ServiceID,
+		StaffID:   series.StaffID,
+		ClientID:  series.ClientID,
+		StartTime: series.StartTime,
+	})
+	data.ValidateRecurrenceRule(v, series.Recurrence, series.StartTime)
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	appointments, skipped, err := app.models.AppointmentSeries.Insert(series)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrStaffServiceMismatch):
+			v.AddError("staff_id", "the selected staff member does not offer this service")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, data.ErrServiceNotFound):
+			v.AddError("service_id", "service not found")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, data.ErrSeriesUnavailable):
+			message := envelope{
+				"message": "none of the occurrences could be booked, please choose another time",
+				"skipped": skipped,
+			}
+			app.errorResponse(w, r, http.StatusConflict, message)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	env := envelope{
+		"series":       series,
+		"appointments": appointments,
+		"skipped":      skipped,
+	}
+
+	err = app.writeJSON(w, http.StatusCreated, env, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) showAppointmentSeriesHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	series, err := app.models.AppointmentSeries.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	user := app.contextGetUser(r)
+
+	ok, err := app.canAccessAppointment(user, &data.Appointment{ProviderID: series.ProviderID, ClientID: series.ClientID})
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if !ok {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	appointments, err := app.models.Appointments.GetAllForSeries(series.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"series": series, "appointments": appointments}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
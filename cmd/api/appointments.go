@@ -0,0 +1,559 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/tormgibbs/snapluks-backend/internal/data"
+	"github.com/tormgibbs/snapluks-backend/internal/validator"
+)
+
+func (app *application) createAppointmentHandler(w http.ResponseWriter, r *http.Request) {
+	user := app.contextGetUser(r)
+
+	if user.Role != data.RoleClient {
+		app.notPermittedResponse(w, r)
+		return
+	}
+
+	var input struct {
+		ServiceID int64     `json:"service_id"`
+		StaffID   int64     `json:"staff_id"`
+		StartTime time.Time `json:"start_time"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	appointment := &data.Appointment{
+		ServiceID: input.ServiceID,
+		StaffID:   input.StaffID,
+		ClientID:  user.ID,
+		StartTime: input.StartTime,
+	}
+
+	v := validator.New()
+
+	if data.ValidateAppointment(v, appointment); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Appointments.Insert(appointment)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrStaffServiceMismatch):
+			v.AddError("staff_id", "the selected staff member does not offer this service")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, data.ErrServiceNotFound):
+			v.AddError("service_id", "service not found")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, data.ErrOutsideBusinessHours):
+			v.AddError("start_time", "falls outside the provider's business hours")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, data.ErrStaffUnavailable):
+			v.AddError("start_time", "the selected staff member is not working at this time")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, data.ErrSlotUnavailable):
+			app.slotUnavailableResponse(w, r)
+		case errors.Is(err, data.ErrResourceUnavailable):
+			app.resourceUnavailableResponse(w, r)
+		case errors.Is(err, data.ErrSessionFull):
+			app.sessionFullResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"appointment": appointment}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) showAppointmentHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	appointment, err := app.models.Appointments.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	user := app.contextGetUser(r)
+
+	ok, err := app.canAccessAppointment(user, appointment)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if !ok {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"appointment": appointment}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) listAppointmentsHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Status string
+		data.Filters
+	}
+
+	v := validator.New()
+
+	qs := r.URL.Query()
+
+	input.Status = app.readString(qs, "status", "")
+	input.Filters.Page = app.readInt(qs, "page", 1, v)
+	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
+	input.Filters.Sort = app.readString(qs, "sort", "start_time")
+	input.Filters.SortSafeList = []string{"id", "start_time", "created_at", "-id", "-start_time", "-created_at"}
+
+	if input.Status != "" {
+		v.Check(validator.In(input.Status, data.AppointmentStatuses...), "status", "invalid status value")
+	}
+
+	if data.ValidateFilters(v, input.Filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	user := app.contextGetUser(r)
+
+	var (
+		appointments []*data.Appointment
+		metadata     data.Metadata
+	)
+
+	switch user.Role {
+	case data.RoleProvider:
+		provider, err := app.models.Providers.GetByUserID(user.ID)
+		if err != nil {
+			switch {
+			case errors.Is(err, data.ErrRecordNotFound):
+				msg := "you must setup a provider profile"
+				app.notPermittedWithMessageResponse(w, r, msg)
+			default:
+				app.serverErrorResponse(w, r, err)
+			}
+			return
+		}
+
+		appointments, metadata, err = app.models.Appointments.GetAllForProvider(provider.ID, input.Status, input.Filters)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+	default:
+		var err error
+
+		appointments, metadata, err = app.models.Appointments.GetAllForClient(user.ID, input.Status, input.Filters)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+	}
+
+	err := app.writeJSON(w, http.StatusOK, envelope{"appointments": appointments, "metadata": metadata}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// canAccessAppointment reports whether the user either booked the appointment
+// or owns the provider profile it was booked with.
+func (app *application) canAccessAppointment(user *data.User, a *data.Appointment) (bool, error) {
+	if a.ClientID == user.ID {
+		return true, nil
+	}
+
+	if user.Role != data.RoleProvider {
+		return false, nil
+	}
+
+	provider, err := app.models.Providers.GetByUserID(user.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			return false, nil
+		default:
+			return false, err
+		}
+	}
+
+	return provider.ID == a.ProviderID, nil
+}
+
+func (app *application) rescheduleAppointmentHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	var input struct {
+		StartTime time.Time `json:"start_time"`
+		StaffID   *int64    `json:"staff_id"`
+		Override  bool      `json:"override"`
+		ApplyTo   string    `json:"apply_to"`
+	}
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	appointment, err := app.models.Appointments.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	user := app.contextGetUser(r)
+
+	ok, err := app.canAccessAppointment(user, appointment)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if !ok {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	v := validator.New()
+
+	v.Check(!input.StartTime.IsZero(), "start_time", "must be provided")
+	v.Check(input.StartTime.After(time.Now()), "start_time", "must be in the future")
+
+	var staffID int64
+	if input.StaffID != nil {
+		staffID = *input.StaffID
+		v.Check(staffID > 0, "staff_id", "must be greater than zero")
+	}
+
+	validateApplyTo(v, input.ApplyTo, appointment)
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	if input.Override {
+		ok, err := app.canOverridePolicy(user, appointment)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		if !ok {
+			app.notPermittedWithMessageResponse(w, r, "only the owner can override the provider's booking policy")
+			return
+		}
+	}
+
+	var (
+		reschedules []*data.AppointmentReschedule
+		skipped     []*data.SkippedOccurrence
+	)
+
+	if input.ApplyTo == applyToFollowing {
+		reschedules, skipped, err = app.models.AppointmentSeries.RescheduleFollowing(appointment, staffID, input.StartTime, user.ID, input.Override)
+	} else {
+		var reschedule *data.AppointmentReschedule
+		reschedule, err = app.models.Appointments.Reschedule(appointment, staffID, input.StartTime, user.ID, input.Override)
+		reschedules = []*data.AppointmentReschedule{reschedule}
+	}
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		case errors.Is(err, data.ErrCancellationNotice), errors.Is(err, data.ErrRescheduleLimit):
+			app.policyViolationResponse(w, r, appointment.ProviderID, err)
+		case errors.Is(err, data.ErrInvalidTransition):
+			v.AddError("status", fmt.Sprintf("cannot reschedule a %s appointment", appointment.Status))
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, data.ErrStaffServiceMismatch):
+			v.AddError("staff_id", "the selected staff member does not offer this service")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, data.ErrOutsideBusinessHours):
+			v.AddError("start_time", "falls outside the provider's business hours")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, data.ErrStaffUnavailable):
+			v.AddError("start_time", "the selected staff member is not working at this time")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, data.ErrSlotUnavailable):
+			app.slotUnavailableResponse(w, r)
+		case errors.Is(err, data.ErrResourceUnavailable):
+			app.resourceUnavailableResponse(w, r)
+		case errors.Is(err, data.ErrSessionFull):
+			app.sessionFullResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	env := envelope{"appointment": appointment, "reschedule": reschedules[0]}
+
+	if input.ApplyTo == applyToFollowing {
+		env = envelope{"appointment": appointment, "reschedules": reschedules, "skipped": skipped}
+	}
+
+	err = app.writeJSON(w, http.StatusOK, env, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) cancelAppointmentHandler(w http.ResponseWriter, r *http.Request) {
+	app.transitionAppointment(w, r, data.AppointmentCancelled)
+}
+
+func (app *application) completeAppointmentHandler(w http.ResponseWriter, r *http.Request) {
+	app.transitionAppointment(w, r, data.AppointmentCompleted)
+}
+
+func (app *application) noShowAppointmentHandler(w http.ResponseWriter, r *http.Request) {
+	app.transitionAppointment(w, r, data.AppointmentNoShow)
+}
+
+// transitionAppointment moves an appointment to the given status on behalf of
+// the current user. Both parties may cancel, but only the provider can mark an
+// appointment as completed or as a no-show, and only once it has started.
+func (app *application) transitionAppointment(w http.ResponseWriter, r *http.Request, to data.AppointmentStatus) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	var input struct {
+		Reason   *string `json:"reason"`
+		Override bool    `json:"override"`
+		ApplyTo  string  `json:"apply_to"`
+	}
+
+	// Every field is optional, so a request without a body is taken as an
+	// empty one.
+	err = app.readJSON(w, r, &input)
+	if err != nil && !errors.Is(err, errEmptyBody) {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	appointment, err := app.models.Appointments.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	user := app.contextGetUser(r)
+
+	ok, err := app.canAccessAppointment(user, appointment)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if !ok {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	if to != data.AppointmentCancelled && user.Role != data.RoleProvider {
+		app.notPermittedResponse(w, r)
+		return
+	}
+
+	v := validator.New()
+
+	if input.Reason != nil {
+		v.Check(len(*input.Reason) <= 1000, "reason", "must not be more than 1000 bytes long")
+	}
+
+	if to != data.AppointmentCancelled {
+		v.Check(!appointment.StartTime.After(time.Now()), "status", fmt.Sprintf("cannot mark an appointment as %s before it starts", to))
+		v.Check(input.ApplyTo == "", "apply_to", "is only supported when cancelling")
+	}
+
+	validateApplyTo(v, input.ApplyTo, appointment)
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	if input.Override {
+		ok, err := app.canOverridePolicy(user, appointment)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		if !ok {
+			app.notPermittedWithMessageResponse(w, r, "only the owner can override the provider's booking policy")
+			return
+		}
+	}
+
+	var changes []*data.AppointmentStatusChange
+
+	if input.ApplyTo == applyToFollowing {
+		changes, err = app.models.AppointmentSeries.CancelFollowing(appointment, user.ID, input.Reason, input.Override)
+	} else {
+		var change *data.AppointmentStatusChange
+		change, err = app.models.Appointments.UpdateStatus(appointment, to, user.ID, input.Reason, input.Override)
+		changes = []*data.AppointmentStatusChange{change}
+	}
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		case errors.Is(err, data.ErrCancellationNotice):
+			app.policyViolationResponse(w, r, appointment.ProviderID, err)
+		case errors.Is(err, data.ErrInvalidTransition):
+			v.AddError("status", fmt.Sprintf("cannot change a %s appointment to %s", appointment.Status, to))
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if to == data.AppointmentCancelled {
+		ids := make([]int64, 0, len(changes))
+		for _, change := range changes {
+			ids = append(ids, change.AppointmentID)
+		}
+		app.notifyWaitlist(ids...)
+	}
+
+	env := envelope{"appointment": appointment, "status_change": changes[0]}
+
+	if input.ApplyTo == applyToFollowing {
+		env = envelope{"appointment": appointment, "status_changes": changes}
+	}
+
+	err = app.writeJSON(w, http.StatusOK, env, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) listAppointmentHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	appointment, err := app.models.Appointments.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	user := app.contextGetUser(r)
+
+	ok, err := app.canAccessAppointment(user, appointment)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if !ok {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	changes, err := app.models.AppointmentStatusChanges.GetAllForAppointment(appointment.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	reschedules, err := app.models.AppointmentReschedules.GetAllForAppointment(appointment.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"status_changes": changes, "reschedules": reschedules}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// canOverridePolicy reports whether the user is the owner staff member of the
+// provider the appointment was booked with.
+func (app *application) canOverridePolicy(user *data.User, a *data.Appointment) (bool, error) {
+	if user.Role != data.RoleProvider {
+		return false, nil
+	}
+
+	return app.models.Staff.IsOwner(a.ProviderID, user.Email)
+}
+
+// policyViolationResponse explains which part of the provider's cancellation
+// policy blocked the change.
+func (app *application) policyViolationResponse(w http.ResponseWriter, r *http.Request, providerID int64, err error) {
+	provider, perr := app.models.Providers.Get(providerID)
+	if perr != nil {
+		app.serverErrorResponse(w, r, perr)
+		return
+	}
+
+	policy := provider.CancellationPolicy
+
+	v := validator.New()
+
+	switch {
+	case errors.Is(err, data.ErrCancellationNotice) && policy.NoticeHours != nil:
+		v.AddError("policy", fmt.Sprintf("this provider does not allow cancellations or rescheduling within %d hours of the appointment", *policy.NoticeHours))
+	case errors.Is(err, data.ErrRescheduleLimit) && policy.MaxReschedules != nil:
+		v.AddError("policy", fmt.Sprintf("this provider allows a booking to be rescheduled at most %d times", *policy.MaxReschedules))
+	default:
+		v.AddError("policy", "this change is not allowed by the provider's booking policy")
+	}
+
+	app.failedValidationResponse(w, r, v.Errors)
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"errors"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/tormgibbs/snapluks-backend/internal/data"
@@ -53,6 +54,7 @@ func (app *application) registerUserHandler(w http.ResponseWriter, r *http.Reque
 		func() {
 			activationData := map[string]any{
 				"activationToken": token.Plaintext,
+				"email":           user.Email,
 				"userID":          user.ID,
 			}
 
@@ -69,8 +71,12 @@ func (app *application) registerUserHandler(w http.ResponseWriter, r *http.Reque
 	}
 }
 
+// verifyEmailHandler activates the account an activation code was sent to.
+// The code must be sent along with the email address it was sent to, so that
+// guesses are counted against the account as well as the client's address.
 func (app *application) verifyEmailHandler(w http.ResponseWriter, r *http.Request) {
 	var input struct {
+		Email          string `json:"email"`
 		TokenPlaintext string `json:"token"`
 	}
 
@@ -82,20 +88,29 @@ func (app *application) verifyEmailHandler(w http.ResponseWriter, r *http.Reques
 
 	v := validator.New()
 
-	if data.ValidateTokenPlaintext(v, input.TokenPlaintext, data.ScopeActivation); !v.Valid() {
+	data.ValidateEmail(v, input.Email)
+	data.ValidateTokenPlaintext(v, input.TokenPlaintext, data.ScopeActivation)
+
+	if !v.Valid() {
 		app.failedValidationResponse(w, r, v.Errors)
 		return
 	}
 
+	keys := throttleKeys(r, input.Email)
+
+	if !app.reserveAttempt(w, r, throttleVerifyEmail, keys) {
+		return
+	}
+
 	user, err := app.models.Users.GetForToken(data.ScopeActivation, input.TokenPlaintext)
-	if err != nil {
-		switch {
-		case errors.Is(err, data.ErrRecordNotFound):
-			v.AddError("token", "invalid or expired activation token")
-			app.failedValidationResponse(w, r, v.Errors)
-		default:
-			app.serverErrorResponse(w, r, err)
-		}
+	if err != nil && !errors.Is(err, data.ErrRecordNotFound) {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if user == nil || !strings.EqualFold(user.Email, input.Email) {
+		v.AddError("token", "invalid or expired activation token")
+		app.failedValidationResponse(w, r, v.Errors)
 		return
 	}
 
@@ -118,6 +133,12 @@ func (app *application) verifyEmailHandler(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
+	err = app.models.AuthThrottles.Succeed(throttleVerifyEmail, keys...)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
 	err = app.writeJSON(w, http.StatusOK, envelope{"user": user}, nil)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
@@ -177,6 +198,7 @@ func (app *application) resendVerificationHandler(w http.ResponseWriter, r *http
 		func() {
 			data := map[string]any{
 				"activationToken": token.Plaintext,
+				"email":           user.Email,
 			}
 
 			err = app.mailer.SendMail(user.Email, "resend_verification.tmpl", data)
@@ -286,6 +308,12 @@ func (app *application) loginHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	keys := throttleKeys(r, input.Email)
+
+	if !app.reserveAttempt(w, r, throttleLogin, keys) {
+		return
+	}
+
 	user, err := app.models.Users.GetByEmail(input.Email)
 	if err != nil {
 		switch {
@@ -313,13 +341,173 @@ func (app *application) loginHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	token, err := app.models.Tokens.New(user.ID, 24*time.Hour, data.ScopeAuthentication)
+	err = app.models.AuthThrottles.Succeed(throttleLogin, keys...)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	access, refresh, err := app.models.Sessions.New(user.ID, r.UserAgent(), authenticationTokenTTL, refreshTokenTTL)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"authentication_token": access, "refresh_token": refresh}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// createPasswordResetTokenHandler emails the user a code they can use to set a
+// new password. Any code sent before is replaced.
+func (app *application) createPasswordResetTokenHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Email string `json:"email"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+
+	if data.ValidateEmail(v, input.Email); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	user, err := app.models.Users.GetByEmail(input.Email)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			v.AddError("email", "no matching email address found")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if !user.Activated {
+		v.AddError("email", "user account must be activated")
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Tokens.DeleteAllForUser(user.ID, data.ScopePasswordReset)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	token, err := app.models.Tokens.New(user.ID, 45*time.Minute, data.ScopePasswordReset)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.background(
+		func() {
+			data := map[string]any{
+				"email":              user.Email,
+				"passwordResetToken": token.Plaintext,
+			}
+
+			err = app.mailer.SendMail(user.Email, "password_reset.tmpl", data)
+			if err != nil {
+				app.logger.PrintError(err, nil)
+			}
+		},
+	)
+
+	err = app.writeJSON(w, http.StatusAccepted, envelope{"message": "an email will be sent to you containing password reset instructions"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// updateUserPasswordHandler sets a new password for the user a reset code was
+// sent to. The code must be sent along with the email address it was sent to,
+// and every session the user had is signed out once the password is changed.
+func (app *application) updateUserPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Email          string `json:"email"`
+		TokenPlaintext string `json:"token"`
+		Password       string `json:"password"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+
+	data.ValidateEmail(v, input.Email)
+	data.ValidateTokenPlaintext(v, input.TokenPlaintext, data.ScopePasswordReset)
+	data.ValidatePasswordPlaintext(v, input.Password)
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	keys := throttleKeys(r, input.Email)
+
+	if !app.reserveAttempt(w, r, throttlePasswordReset, keys) {
+		return
+	}
+
+	user, err := app.models.Users.GetForToken(data.ScopePasswordReset, input.TokenPlaintext)
+	if err != nil && !errors.Is(err, data.ErrRecordNotFound) {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if user == nil || !strings.EqualFold(user.Email, input.Email) {
+		v.AddError("token", "invalid or expired password reset token")
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = user.Password.Set(input.Password)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.models.Users.Update(user)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	for _, scope := range []string{data.ScopePasswordReset, data.ScopeAuthentication, data.ScopeRefresh} {
+		err = app.models.Tokens.DeleteAllForUser(user.ID, scope)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+	}
+
+	err = app.models.AuthThrottles.Succeed(throttlePasswordReset, keys...)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	// A new password also lifts any lockout on logging in to the account.
+	err = app.models.AuthThrottles.Reset(throttleLogin, keys[0])
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 		return
 	}
 
-	err = app.writeJSON(w, http.StatusCreated, envelope{"authentication_token": token}, nil)
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "your password was successfully reset"}, nil)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 	}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/tormgibbs/snapluks-backend/internal/data"
+)
+
+// The actions whose failed attempts are throttled.
+const (
+	throttleLogin         = "login"
+	throttleVerifyEmail   = "verify-email"
+	throttlePasswordReset = "password-reset"
+)
+
+// throttleSweepInterval is how often failed attempts that have been forgotten
+// are cleared out.
+const throttleSweepInterval = 10 * time.Minute
+
+// clientIP returns the address the request came from, without its port.
+func clientIP(r *http.Request) string {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return ip
+}
+
+// throttleKeys returns the keys failed attempts on the account with the given
+// email address are counted against: the account and the client's address.
+func throttleKeys(r *http.Request, email string) []data.ThrottleKey {
+	return []data.ThrottleKey{
+		data.AccountThrottleKey(email),
+		data.IPThrottleKey(clientIP(r)),
+	}
+}
+
+// reserveAttempt counts an attempt at the action against the keys before it
+// is made. It writes a 429 response and returns false if the action is locked
+// out for any of the keys. A successful attempt must be reported with
+// AuthThrottles.Succeed, and a failed one needs nothing further.
+func (app *application) reserveAttempt(w http.ResponseWriter, r *http.Request, action string, keys []data.ThrottleKey) bool {
+	retryAfter, err := app.models.AuthThrottles.Attempt(action, keys...)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return false
+	}
+
+	if retryAfter > 0 {
+		app.tooManyAttemptsResponse(w, r, retryAfter)
+		return false
+	}
+
+	return true
+}
+
+// sweepAuthThrottles starts clearing out forgotten failed attempts every
+// throttleSweepInterval.
+func (app *application) sweepAuthThrottles() {
+	app.every(throttleSweepInterval, func() {
+		deleted, err := app.models.AuthThrottles.DeleteStale()
+		if err != nil {
+			app.logger.PrintError(err, nil)
+			return
+		}
+
+		if deleted > 0 {
+			app.logger.PrintInfo("cleared stale failed attempts", map[string]string{
+				"count": fmt.Sprint(deleted),
+			})
+		}
+	})
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/tormgibbs/snapluks-backend/internal/data"
+	"github.com/tormgibbs/snapluks-backend/internal/validator"
+)
+
+func (app *application) showAvailabilityHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		ProviderID int
+		ServiceID  int
+		ServiceIDs []int64
+		StaffID    int
+		Date       string
+	}
+
+	v := validator.New()
+
+	qs := r.URL.Query()
+
+	input.ProviderID = app.readInt(qs, "provider_id", 0, v)
+	input.ServiceID = app.readInt(qs, "service_id", 0, v)
+	input.StaffID = app.readInt(qs, "staff_id", 0, v)
+	input.Date = app.readString(qs, "date", "")
+
+	serviceIDs, err := data.ParseIntSlice[int64](app.readCSV(qs, "service_ids", nil))
+	if err != nil {
+		v.AddError("service_ids", "must be a comma-separated list of integers")
+	}
+	input.ServiceIDs = serviceIDs
+
+	v.Check(input.ProviderID > 0, "provider_id", "must be provided and greater than zero")
+
+	if len(input.ServiceIDs) == 0 {
+		v.Check(input.ServiceID > 0, "service_id", "must be provided and greater than zero")
+	} else {
+		v.Check(input.ServiceID == 0, "service_id", "must not be provided together with service_ids")
+		v.Check(len(input.ServiceIDs) <= 5, "service_ids", "must not contain more than 5 services")
+		for _, id := range input.ServiceIDs {
+			v.Check(id > 0, "service_ids", "must only contain values greater than zero")
+		}
+	}
+	v.Check(input.StaffID >= 0, "staff_id", "must not be negative")
+
+	day, err := time.Parse(time.DateOnly, input.Date)
+	if err != nil {
+		v.AddError("date", "must be a valid date (e.g. '2025-07-01')")
+	}
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	var slots any
+
+	if len(input.ServiceIDs) > 0 {
+		slots, err = app.models.Availability.GetForServices(int64(input.ProviderID), input.ServiceIDs, int64(input.StaffID), day)
+	} else {
+		slots, err = app.models.Availability.GetForService(int64(input.ProviderID), int64(input.ServiceID), int64(input.StaffID), day)
+	}
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			v.AddError("provider_id", "provider not found")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, data.ErrServiceNotFound):
+			v.AddError("service_id", "service not found for this provider")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, data.ErrStaffServiceMismatch):
+			v.AddError("staff_id", "the selected staff member does not offer this service")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"date": input.Date, "slots": slots}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/tormgibbs/snapluks-backend/internal/data"
+	"github.com/tormgibbs/snapluks-backend/internal/ical"
+	"github.com/tormgibbs/snapluks-backend/internal/validator"
+)
+
+// calendarFeedTTL is how long a calendar feed URL keeps working unless it is
+// revoked or replaced first.
+const calendarFeedTTL = 5 * 365 * 24 * time.Hour
+
+func calendarFeedURL(token *data.Token) string {
+	return "/api/v1/calendar/" + token.Plaintext + ".ics"
+}
+
+// createCalendarFeedHandler creates a secret feed URL for the authenticated
+// client's or provider's appointments. Any URL created before stops working.
+func (app *application) createCalendarFeedHandler(w http.ResponseWriter, r *http.Request) {
+	user := app.contextGetUser(r)
+
+	if user.Role == data.RoleProvider {
+		_, err := app.models.Providers.GetByUserID(user.ID)
+		if err != nil {
+			switch {
+			case errors.Is(err, data.ErrRecordNotFound):
+				msg := "you must setup a provider profile"
+				app.notPermittedWithMessageResponse(w, r, msg)
+			default:
+				app.serverErrorResponse(w, r, err)
+			}
+			return
+		}
+	}
+
+	token, err := app.models.CalendarFeeds.New(user.ID, nil, calendarFeedTTL)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"calendar_feed": token, "url": calendarFeedURL(token)}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) revokeCalendarFeedHandler(w http.ResponseWriter, r *http.Request) {
+	user := app.contextGetUser(r)
+
+	err := app.models.CalendarFeeds.Revoke(user.ID, nil)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "calendar feed successfully revoked"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// createStaffCalendarFeedHandler creates a secret feed URL for a staff
+// member's appointments, which the provider can pass on to them.
+func (app *application) createStaffCalendarFeedHandler(w http.ResponseWriter, r *http.Request) {
+	staff, ok := app.staffForProvider(w, r)
+	if !ok {
+		return
+	}
+
+	user := app.contextGetUser(r)
+
+	token, err := app.models.CalendarFeeds.New(user.ID, &staff.ID, calendarFeedTTL)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"calendar_feed": token, "url": calendarFeedURL(token)}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) revokeStaffCalendarFeedHandler(w http.ResponseWriter, r *http.Request) {
+	staff, ok := app.staffForProvider(w, r)
+	if !ok {
+		return
+	}
+
+	user := app.contextGetUser(r)
+
+	err := app.models.CalendarFeeds.Revoke(user.ID, &staff.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "calendar feed successfully revoked"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// showCalendarFeedHandler renders the appointments a feed token gives access
+// to as an iCalendar file. Calendar apps cannot send an Authorization header,
+// so the token in the URL is the only credential.
+func (app *application) showCalendarFeedHandler(w http.ResponseWriter, r *http.Request) {
+	tokenPlaintext := strings.TrimSuffix(httprouter.ParamsFromContext(r.Context()).ByName("token"), ".ics")
+
+	v := validator.New()
+
+	if data.ValidateTokenPlaintext(v, tokenPlaintext, data.ScopeCalendarFeed); !v.Valid() {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	feed, err := app.models.CalendarFeeds.GetForToken(tokenPlaintext)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	cal := ical.Calendar{Name: "Snapluks appointments"}
+
+	for _, e := range feed.Entries {
+		event := ical.Event{
+			UID:       fmt.Sprintf("appointment-%d@snapluks", e.AppointmentID),
+			Start:     e.StartTime,
+			End:       e.EndTime,
+			Location:  e.Address,
+			Cancelled: e.Status == data.AppointmentCancelled,
+		}
+
+		// Clients want to know where they are going and who they are seeing,
+		// while staff and providers want to know who is coming. A provider's
+		// feed covers all of its staff, so it also says who is seeing them.
+		switch {
+		case feed.StaffID != nil:
+			event.Summary = fmt.Sprintf("%s - %s", e.ServiceName, e.ClientName)
+			event.Description = "Client: " + e.ClientName
+		case feed.Role == data.RoleClient:
+			event.Summary = fmt.Sprintf("%s at %s", e.ServiceName, e.ProviderName)
+			event.Description = "With " + e.StaffName
+		default:
+			event.Summary = fmt.Sprintf("%s - %s", e.ServiceName, e.ClientName)
+			event.Description = fmt.Sprintf("Client: %s\nStaff: %s", e.ClientName, e.StaffName)
+		}
+
+		cal.Events = append(cal.Events, event)
+	}
+
+	var buf bytes.Buffer
+
+	err = cal.Write(&buf)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Content-Disposition", `inline; filename="snapluks.ics"`)
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	w.Write(buf.Bytes())
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"syscall"
+	"time"
+)
+
+var errNonPublicAddress = errors.New("calendar URL resolves to a non-public address")
+
+// maxCalendarRedirects caps how many redirects are followed when fetching a
+// calendar.
+const maxCalendarRedirects = 5
+
+// nonPublicPrefixes lists the reserved ranges that netip does not classify as
+// private, loopback or link-local but that are still not on the internet.
+var nonPublicPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("192.0.2.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("198.51.100.0/24"),
+	netip.MustParsePrefix("203.0.113.0/24"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+	netip.MustParsePrefix("64:ff9b::/96"),
+	netip.MustParsePrefix("2001:db8::/32"),
+}
+
+// isPublicAddress reports whether addr is a unicast address on the public
+// internet. IPv4 addresses mapped into IPv6 are judged as IPv4.
+func isPublicAddress(addr netip.Addr) bool {
+	addr = addr.Unmap()
+
+	if !addr.IsGlobalUnicast() || addr.IsPrivate() {
+		return false
+	}
+
+	for _, prefix := range nonPublicPrefixes {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// dialPublicOnly is a dialer Control hook that refuses to connect to anything
+// but public addresses. It runs after the host name has been resolved, so a
+// name pointing at an internal address is caught as well.
+func dialPublicOnly(network, address string, _ syscall.RawConn) error {
+	ap, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return err
+	}
+
+	if !isPublicAddress(ap.Addr()) {
+		return errNonPublicAddress
+	}
+
+	return nil
+}
+
+// calendarFetcher downloads the calendars that sources point to.
+type calendarFetcher struct {
+	client *http.Client
+}
+
+// newCalendarFetcher returns a fetcher that only connects to public addresses.
+// Every connection goes through the same dialer, so redirects are checked as
+// well. Proxies from the environment are ignored, since the proxy would make
+// the connection on the fetcher's behalf.
+func newCalendarFetcher() *calendarFetcher {
+	dialer := &net.Dialer{
+		Timeout: 5 * time.Second,
+		Control: dialPublicOnly,
+	}
+
+	transport := &http.Transport{
+		Proxy:                  nil,
+		DialContext:            dialer.DialContext,
+		TLSHandshakeTimeout:    5 * time.Second,
+		ResponseHeaderTimeout:  5 * time.Second,
+		MaxResponseHeaderBytes: 64 << 10,
+	}
+
+	client := &http.Client{
+		Transport: transport,
+		Timeout:   10 * time.Second,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxCalendarRedirects {
+				return errors.New("too many redirects")
+			}
+			if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+				return fmt.Errorf("redirected to unsupported scheme %q", req.URL.Scheme)
+			}
+			return nil
+		},
+	}
+
+	return &calendarFetcher{client: client}
+}
+
+// fetch downloads the calendar at rawURL. Webcal URLs are fetched over HTTPS.
+func (f *calendarFetcher) fetch(rawURL string) ([]byte, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	switch u.Scheme {
+	case "http", "https":
+	case "webcal":
+		u.Scheme = "https"
+	default:
+		return nil, fmt.Errorf("unsupported calendar URL scheme %q", u.Scheme)
+	}
+
+	resp, err := f.client.Get(u.String())
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("calendar server responded with %s", resp.Status)
+	}
+
+	raw, err := io.ReadAll(io.LimitReader(resp.Body, maxCalendarSize+1))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(raw) > maxCalendarSize {
+		return nil, errors.New("calendar is larger than 2MB")
+	}
+
+	return raw, nil
+}
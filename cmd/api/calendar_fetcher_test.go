@@ -0,0 +1,111 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"testing"
+)
+
+func TestIsPublicAddress(t *testing.T) {
+	tests := []struct {
+		addr string
+		want bool
+	}{
+		{"93.184.216.34", true},
+		{"2606:2800:220:1:248:1893:25c8:1946", true},
+		{"127.0.0.1", false},
+		{"::1", false},
+		{"10.1.2.3", false},
+		{"172.16.0.1", false},
+		{"192.168.1.1", false},
+		{"169.254.169.254", false},
+		{"fe80::1", false},
+		{"fd00::1", false},
+		{"0.0.0.0", false},
+		{"::", false},
+		{"100.64.0.1", false},
+		{"224.0.0.1", false},
+		{"255.255.255.255", false},
+		{"::ffff:127.0.0.1", false},
+		{"::ffff:10.0.0.1", false},
+		{"64:ff9b::a00:1", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.addr, func(t *testing.T) {
+			got := isPublicAddress(netip.MustParseAddr(tt.addr))
+			if got != tt.want {
+				t.Errorf("isPublicAddress(%s) = %t, want %t", tt.addr, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCalendarFetcherRefusesNonPublicAddresses(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "BEGIN:VCALENDAR\r\nEND:VCALENDAR\r\n")
+	}))
+	defer srv.Close()
+
+	_, err := newCalendarFetcher().fetch(srv.URL)
+	if !errors.Is(err, errNonPublicAddress) {
+		t.Fatalf("got error %v, want %v", err, errNonPublicAddress)
+	}
+
+	// A host name that resolves to loopback is refused just the same.
+	_, err = newCalendarFetcher().fetch(strings.Replace(srv.URL, "127.0.0.1", "localhost", 1))
+	if !errors.Is(err, errNonPublicAddress) {
+		t.Fatalf("got error %v, want %v", err, errNonPublicAddress)
+	}
+}
+
+func TestCalendarFetcherFetch(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/calendar.ics", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "BEGIN:VCALENDAR\r\nEND:VCALENDAR\r\n")
+	})
+	mux.HandleFunc("/missing.ics", func(w http.ResponseWriter, r *http.Request) {
+		http.NotFound(w, r)
+	})
+	mux.HandleFunc("/large.ics", func(w http.ResponseWriter, r *http.Request) {
+		w.Write(make([]byte, maxCalendarSize+1))
+	})
+
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	// The stand-in server is on loopback, so the fetcher is given a client
+	// that is allowed to reach it.
+	f := &calendarFetcher{client: srv.Client()}
+
+	raw, err := f.fetch(srv.URL + "/calendar.ics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(raw), "BEGIN:VCALENDAR") {
+		t.Errorf("got %q", raw)
+	}
+
+	tests := []struct {
+		name string
+		url  string
+	}{
+		{"not found", srv.URL + "/missing.ics"},
+		{"too large", srv.URL + "/large.ics"},
+		{"file scheme", "file:///etc/passwd"},
+		{"ftp scheme", "ftp://example.com/calendar.ics"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := f.fetch(tt.url)
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+}
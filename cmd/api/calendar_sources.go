@@ -0,0 +1,344 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/tormgibbs/snapluks-backend/internal/data"
+	"github.com/tormgibbs/snapluks-backend/internal/ical"
+	"github.com/tormgibbs/snapluks-backend/internal/validator"
+)
+
+var (
+	errCalendarUnreachable = errors.New("the calendar could not be downloaded")
+	errCalendarInvalid     = errors.New("the calendar is not a valid iCalendar file")
+)
+
+const (
+	// calendarImportHorizon is how far ahead busy times are imported from
+	// external calendars.
+	calendarImportHorizon = 180 * 24 * time.Hour
+
+	// maxCalendarSize caps the size of an imported calendar, in bytes.
+	maxCalendarSize = 2 << 20
+)
+
+// readBusyTimes parses a calendar and returns the staff member's busy times
+// from now until calendarImportHorizon. Dates and floating times are read in
+// the provider's time zone, loc.
+func readBusyTimes(raw []byte, staff *data.Staff, loc *time.Location) ([]*data.ExternalBusyTime, error) {
+	now := time.Now()
+
+	periods, err := ical.ParseBusy(bytes.NewReader(raw), loc, now, now.Add(calendarImportHorizon))
+	if err != nil {
+		return nil, err
+	}
+
+	busy := make([]*data.ExternalBusyTime, 0, len(periods))
+	for _, p := range periods {
+		busy = append(busy, &data.ExternalBusyTime{
+			StaffID:    staff.ID,
+			ProviderID: staff.ProviderID,
+			UID:        p.UID,
+			StartTime:  p.Start,
+			EndTime:    p.End,
+		})
+	}
+
+	return busy, nil
+}
+
+// syncCalendarSource imports the busy times of a calendar source and records
+// the outcome on it. A calendar that cannot be fetched or read is recorded as
+// the source's last error, leaving the busy times of the last successful sync
+// in place, so only server errors are returned.
+func (app *application) syncCalendarSource(source *data.CalendarSource, staff *data.Staff) error {
+	provider, err := app.models.Providers.Get(staff.ProviderID)
+	if err != nil {
+		return err
+	}
+
+	loc, err := provider.Location()
+	if err != nil {
+		return err
+	}
+
+	var (
+		busy    []*data.ExternalBusyTime
+		syncErr error
+	)
+
+	// The details of why a calendar could not be imported are only logged,
+	// since they can quote whatever the server at the source's URL sent back.
+	props := map[string]string{"calendar_source_id": strconv.FormatInt(source.ID, 10)}
+
+	raw, err := app.calendars.fetch(source.URL)
+	if err != nil {
+		app.logger.PrintError(err, props)
+		syncErr = errCalendarUnreachable
+	} else {
+		busy, err = readBusyTimes(raw, staff, loc)
+		if err != nil {
+			app.logger.PrintError(err, props)
+			syncErr = errCalendarInvalid
+		}
+	}
+
+	if syncErr == nil {
+		err := app.models.ExternalBusyTimes.Replace(staff.ID, staff.ProviderID, &source.ID, busy)
+		if err != nil {
+			return err
+		}
+	}
+
+	return app.models.CalendarSources.RecordSync(source, syncErr)
+}
+
+// importBusyTimesHandler reads an uploaded iCalendar file and blocks the staff
+// member's availability during its events. Each upload replaces the busy times
+// of the one before.
+func (app *application) importBusyTimesHandler(w http.ResponseWriter, r *http.Request) {
+	staff, ok := app.staffForProvider(w, r)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		Calendar *multipart.FileHeader `form:"calendar"`
+	}
+
+	err := app.readMultipartForm(r, maxCalendarSize, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+
+	v.Check(input.Calendar != nil, "calendar", "must be provided")
+	if input.Calendar != nil {
+		v.Check(input.Calendar.Size <= maxCalendarSize, "calendar", "must not be larger than 2MB")
+	}
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	file, err := input.Calendar.Open()
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	defer file.Close()
+
+	raw, err := io.ReadAll(io.LimitReader(file, maxCalendarSize))
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	provider, err := app.models.Providers.Get(staff.ProviderID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	loc, err := provider.Location()
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	busy, err := readBusyTimes(raw, staff, loc)
+	if err != nil {
+		v.AddError("calendar", fmt.Sprintf("must be a valid iCalendar file (%s)", err))
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.ExternalBusyTimes.Replace(staff.ID, staff.ProviderID, nil, busy)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.showBusyTimes(w, r, staff)
+}
+
+func (app *application) listBusyTimesHandler(w http.ResponseWriter, r *http.Request) {
+	staff, ok := app.staffForProvider(w, r)
+	if !ok {
+		return
+	}
+
+	app.showBusyTimes(w, r, staff)
+}
+
+// showBusyTimes writes the staff member's imported busy times from now until
+// calendarImportHorizon.
+func (app *application) showBusyTimes(w http.ResponseWriter, r *http.Request, staff *data.Staff) {
+	now := time.Now()
+	window := data.Interval{Start: now, End: now.Add(calendarImportHorizon)}
+
+	busy, err := app.models.ExternalBusyTimes.GetAllForStaff(staff.ID, window)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"busy_times": busy}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// createCalendarSourceHandler registers an external calendar for the staff
+// member and imports it straight away.
+func (app *application) createCalendarSourceHandler(w http.ResponseWriter, r *http.Request) {
+	staff, ok := app.staffForProvider(w, r)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		URL string `json:"url"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	source := &data.CalendarSource{
+		StaffID:    staff.ID,
+		ProviderID: staff.ProviderID,
+		URL:        input.URL,
+	}
+
+	v := validator.New()
+
+	if data.ValidateCalendarSource(v, source); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.CalendarSources.Insert(source)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrDuplicateRecord):
+			v.AddError("url", "this calendar has already been added")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.syncCalendarSource(source, staff)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"calendar_source": source}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) listCalendarSourcesHandler(w http.ResponseWriter, r *http.Request) {
+	staff, ok := app.staffForProvider(w, r)
+	if !ok {
+		return
+	}
+
+	sources, err := app.models.CalendarSources.GetAllForStaff(staff.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"calendar_sources": sources}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// syncCalendarSourceHandler imports a registered calendar again. Whether it
+// could be read is reported in the source's last_error.
+func (app *application) syncCalendarSourceHandler(w http.ResponseWriter, r *http.Request) {
+	staff, ok := app.staffForProvider(w, r)
+	if !ok {
+		return
+	}
+
+	sourceID, err := app.readNamedIDParam(r, "source_id")
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	source, err := app.models.CalendarSources.Get(sourceID, staff.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.syncCalendarSource(source, staff)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"calendar_source": source}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) deleteCalendarSourceHandler(w http.ResponseWriter, r *http.Request) {
+	staff, ok := app.staffForProvider(w, r)
+	if !ok {
+		return
+	}
+
+	sourceID, err := app.readNamedIDParam(r, "source_id")
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	err = app.models.CalendarSources.Delete(sourceID, staff.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "calendar source successfully deleted"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
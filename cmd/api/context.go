@@ -9,7 +9,10 @@ import (
 
 type contextKey string
 
-const userContextKey = contextKey("user")
+const (
+	userContextKey    = contextKey("user")
+	sessionContextKey = contextKey("session")
+)
 
 func (app *application) contextGetUser(r *http.Request) *data.User {
 	user, ok := r.Context().Value(userContextKey).(*data.User)
@@ -28,3 +31,16 @@ func (app *application) contextGetUserSafe(r *http.Request) (*data.User, bool) {
 	user, ok := r.Context().Value(userContextKey).(*data.User)
 	return user, ok
 }
+
+func (app *application) contextGetSession(r *http.Request) *data.Session {
+	session, ok := r.Context().Value(sessionContextKey).(*data.Session)
+	if !ok {
+		panic("missing session value in request context")
+	}
+	return session
+}
+
+func (app *application) contextSetSession(r *http.Request, session *data.Session) *http.Request {
+	ctx := context.WithValue(r.Context(), sessionContextKey, session)
+	return r.WithContext(ctx)
+}
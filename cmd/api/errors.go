@@ -2,7 +2,10 @@ package main
 
 import (
 	"fmt"
+	"math"
 	"net/http"
+	"strconv"
+	"time"
 )
 
 func (app *application) logError(r *http.Request, err error) {
@@ -52,6 +55,26 @@ func (app *application) editConflictResponse(w http.ResponseWriter, r *http.Requ
 	app.errorResponse(w, r, http.StatusConflict, message)
 }
 
+func (app *application) slotUnavailableResponse(w http.ResponseWriter, r *http.Request) {
+	message := "the selected time slot is no longer available, please choose another time"
+	app.errorResponse(w, r, http.StatusConflict, message)
+}
+
+func (app *application) resourceUnavailableResponse(w http.ResponseWriter, r *http.Request) {
+	message := "a room or piece of equipment this service needs is fully booked at the selected time, please choose another time"
+	app.errorResponse(w, r, http.StatusConflict, message)
+}
+
+func (app *application) sessionFullResponse(w http.ResponseWriter, r *http.Request) {
+	message := "this session is fully booked, please choose another time"
+	app.errorResponse(w, r, http.StatusConflict, message)
+}
+
+func (app *application) holdExpiredResponse(w http.ResponseWriter, r *http.Request) {
+	message := "your hold on this time slot has expired, please choose a time again"
+	app.errorResponse(w, r, http.StatusConflict, message)
+}
+
 func (app *application) invalidAuthenticationTokenResponse(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("WWW-Authenticate", `Basic realm="Restricted"`)
 
@@ -59,6 +82,20 @@ func (app *application) invalidAuthenticationTokenResponse(w http.ResponseWriter
 	app.errorResponse(w, r, http.StatusUnauthorized, message)
 }
 
+func (app *application) invalidRefreshTokenResponse(w http.ResponseWriter, r *http.Request) {
+	message := "invalid or expired refresh token, please log in again"
+	app.errorResponse(w, r, http.StatusUnauthorized, message)
+}
+
+// tooManyAttemptsResponse tells the client that the action has been locked
+// out after too many failed attempts, and when it can be tried again.
+func (app *application) tooManyAttemptsResponse(w http.ResponseWriter, r *http.Request, retryAfter time.Duration) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+
+	message := "too many failed attempts, please try again later"
+	app.errorResponse(w, r, http.StatusTooManyRequests, message)
+}
+
 func (app *application) notPermittedResponse(w http.ResponseWriter, r *http.Request) {
 	message := "your account doesn't have the necessary permissions to access this resource"
 	app.errorResponse(w, r, http.StatusForbidden, message)
@@ -25,11 +25,15 @@ type envelope map[string]any
 var validImageExts = []string{".jpg", ".jpeg", ".png", ".gif", ".webp"}
 
 func (app *application) readIDParam(r *http.Request) (int64, error) {
+	return app.readNamedIDParam(r, "id")
+}
+
+func (app *application) readNamedIDParam(r *http.Request, name string) (int64, error) {
 	params := httprouter.ParamsFromContext(r.Context())
 
-	id, err := strconv.ParseInt(params.ByName("id"), 10, 64)
+	id, err := strconv.ParseInt(params.ByName(name), 10, 64)
 	if err != nil || id < 1 {
-		return 0, errors.New("invalid id parameter")
+		return 0, fmt.Errorf("invalid %s parameter", name)
 	}
 
 	return id, nil
@@ -87,6 +91,21 @@ func (app *application) readFloat(qs url.Values, key string, defaultValue *float
 	return &f
 }
 
+// parseOptionalInt parses s as an integer, treating an empty string as a
+// request to clear the value.
+func parseOptionalInt(s string) (*int, error) {
+	if s == "" {
+		return nil, nil
+	}
+
+	i, err := strconv.Atoi(s)
+	if err != nil {
+		return nil, err
+	}
+
+	return &i, nil
+}
+
 func (app *application) writeJSON(w http.ResponseWriter, status int, data envelope, headers http.Header) error {
 	for key, value := range headers {
 		w.Header()[key] = value
@@ -105,6 +124,10 @@ func (app *application) writeJSON(w http.ResponseWriter, status int, data envelo
 	return nil
 }
 
+// errEmptyBody is returned by readJSON when the request has no body, so that
+// handlers whose input is all optional can accept one.
+var errEmptyBody = errors.New("body must not be empty")
+
 func (app *application) readJSON(w http.ResponseWriter, r *http.Request, v interface{}) error {
 	maxBytes := 1_048_576
 	r.Body = http.MaxBytesReader(w, r.Body, int64(maxBytes))
@@ -134,7 +157,7 @@ func (app *application) readJSON(w http.ResponseWriter, r *http.Request, v inter
 			return fmt.Errorf("body contains incorrect JSON type (at character %d)", unmarshalTypeError.Offset)
 
 		case errors.Is(err, io.EOF):
-			return errors.New("body must not be empty")
+			return errEmptyBody
 
 		case strings.HasPrefix(err.Error(), "json: unknown field"):
 			fieldName := strings.Trim(strings.TrimPrefix(err.Error(), "json: unknown field "), `"`)
@@ -171,6 +194,20 @@ func (app *application) background(fn func()) {
 	}()
 }
 
+// every runs fn once every interval for as long as the application runs. Each
+// run is a background task, so it is recovered from if it panics and waited
+// for like any other.
+func (app *application) every(interval time.Duration, fn func()) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			app.background(fn)
+		}
+	}()
+}
+
 func (app *application) uploadImageToS3(fileHeader *multipart.FileHeader) (string, error) {
 	file, err := fileHeader.Open()
 	if err != nil {
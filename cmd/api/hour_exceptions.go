@@ -0,0 +1,260 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/tormgibbs/snapluks-backend/internal/data"
+	"github.com/tormgibbs/snapluks-backend/internal/validator"
+)
+
+func (app *application) createHourExceptionHandler(w http.ResponseWriter, r *http.Request) {
+	user := app.contextGetUser(r)
+
+	if user.Role != data.RoleProvider {
+		app.notPermittedResponse(w, r)
+		return
+	}
+
+	provider, err := app.models.Providers.GetByUserID(user.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			msg := "you must setup a provider profile"
+			app.notPermittedWithMessageResponse(w, r, msg)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	var input struct {
+		StartDate data.Date       `json:"start_date"`
+		EndDate   *data.Date      `json:"end_date"`
+		IsClosed  bool            `json:"is_closed"`
+		OpenTime  *data.LocalTime `json:"open_time"`
+		CloseTime *data.LocalTime `json:"close_time"`
+		Reason    *string         `json:"reason"`
+	}
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	exception := &data.ProviderHourException{
+		ProviderID: provider.ID,
+		StartDate:  input.StartDate,
+		EndDate:    input.StartDate,
+		IsClosed:   input.IsClosed,
+		OpenTime:   input.OpenTime,
+		CloseTime:  input.CloseTime,
+		Reason:     input.Reason,
+	}
+
+	if input.EndDate != nil {
+		exception.EndDate = *input.EndDate
+	}
+
+	v := validator.New()
+
+	if data.ValidateProviderHourException(v, exception); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.ProviderHourExceptions.Insert(exception)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrDuplicateRecord):
+			v.AddError("start_date", "an exception already covers one or more of these dates")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"hour_exception": exception}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) listHourExceptionsHandler(w http.ResponseWriter, r *http.Request) {
+	user := app.contextGetUser(r)
+
+	if user.Role != data.RoleProvider {
+		app.notPermittedResponse(w, r)
+		return
+	}
+
+	provider, err := app.models.Providers.GetByUserID(user.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			msg := "you must setup a provider profile"
+			app.notPermittedWithMessageResponse(w, r, msg)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	exceptions, err := app.models.ProviderHourExceptions.GetAllForProvider(provider.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"hour_exceptions": exceptions}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) updateHourExceptionHandler(w http.ResponseWriter, r *http.Request) {
+	user := app.contextGetUser(r)
+
+	if user.Role != data.RoleProvider {
+		app.notPermittedResponse(w, r)
+		return
+	}
+
+	provider, err := app.models.Providers.GetByUserID(user.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			msg := "you must setup a provider profile"
+			app.notPermittedWithMessageResponse(w, r, msg)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	exception, err := app.models.ProviderHourExceptions.Get(id, provider.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	var input struct {
+		StartDate *data.Date      `json:"start_date"`
+		EndDate   *data.Date      `json:"end_date"`
+		IsClosed  *bool           `json:"is_closed"`
+		OpenTime  *data.LocalTime `json:"open_time"`
+		CloseTime *data.LocalTime `json:"close_time"`
+		Reason    *string         `json:"reason"`
+	}
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if input.StartDate != nil {
+		exception.StartDate = *input.StartDate
+	}
+	if input.EndDate != nil {
+		exception.EndDate = *input.EndDate
+	}
+	if input.IsClosed != nil {
+		exception.IsClosed = *input.IsClosed
+		if exception.IsClosed {
+			exception.OpenTime = nil
+			exception.CloseTime = nil
+		}
+	}
+	if input.OpenTime != nil {
+		exception.OpenTime = input.OpenTime
+	}
+	if input.CloseTime != nil {
+		exception.CloseTime = input.CloseTime
+	}
+	if input.Reason != nil {
+		exception.Reason = input.Reason
+	}
+
+	v := validator.New()
+
+	if data.ValidateProviderHourException(v, exception); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.ProviderHourExceptions.Update(exception)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		case errors.Is(err, data.ErrDuplicateRecord):
+			v.AddError("start_date", "an exception already covers one or more of these dates")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"hour_exception": exception}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) deleteHourExceptionHandler(w http.ResponseWriter, r *http.Request) {
+	user := app.contextGetUser(r)
+
+	if user.Role != data.RoleProvider {
+		app.notPermittedResponse(w, r)
+		return
+	}
+
+	provider, err := app.models.Providers.GetByUserID(user.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			msg := "you must setup a provider profile"
+			app.notPermittedWithMessageResponse(w, r, msg)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	err = app.models.ProviderHourExceptions.Delete(id, provider.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "hour exception successfully deleted"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
@@ -11,6 +11,7 @@ import (
 	"strconv"
 	"sync"
 	"time"
+	_ "time/tzdata"
 
 	"github.com/joho/godotenv"
 	"github.com/tormgibbs/snapluks-backend/internal/mailer"
@@ -43,12 +44,13 @@ type config struct {
 }
 
 type application struct {
-	config   config
-	mailer   mailer.Mailer
-	models   data.Models
-	wg       sync.WaitGroup
-	logger   *jsonlog.Logger
-	s3Client *s3.Client
+	config    config
+	mailer    mailer.Mailer
+	models    data.Models
+	wg        sync.WaitGroup
+	logger    *jsonlog.Logger
+	s3Client  *s3.Client
+	calendars *calendarFetcher
 }
 
 func main() {
@@ -70,13 +72,17 @@ func main() {
 	logger.PrintInfo("database connection pool established", nil)
 
 	app := &application{
-		config:   cfg,
-		logger:   logger,
-		models:   data.NewModels(db),
-		mailer:   mailer.New(cfg.smtp.host, cfg.smtp.port, cfg.smtp.user, cfg.smtp.pass, cfg.smtp.sender),
-		s3Client: s3Client,
+		config:    cfg,
+		logger:    logger,
+		models:    data.NewModels(db),
+		mailer:    mailer.New(cfg.smtp.host, cfg.smtp.port, cfg.smtp.user, cfg.smtp.pass, cfg.smtp.sender),
+		s3Client:  s3Client,
+		calendars: newCalendarFetcher(),
 	}
 
+	app.sweepExpiredHolds()
+	app.sweepAuthThrottles()
+
 	mux := http.NewServeMux()
 	mux.HandleFunc("/v1/healthcheck", app.healthcheckHandler)
 
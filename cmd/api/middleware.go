@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/tormgibbs/snapluks-backend/internal/data"
 	"github.com/tormgibbs/snapluks-backend/internal/validator"
@@ -58,7 +59,7 @@ func (app *application) authenticate(next http.HandlerFunc) http.HandlerFunc {
 			return
 		}
 
-		user, err := app.models.Users.GetForToken(data.ScopeAuthentication, token)
+		session, user, err := app.models.Sessions.GetForToken(token)
 		if err != nil {
 			switch {
 			case errors.Is(err, data.ErrRecordNotFound):
@@ -69,7 +70,19 @@ func (app *application) authenticate(next http.HandlerFunc) http.HandlerFunc {
 			return
 		}
 
+		// The last use of a session is only recorded once every
+		// sessionTouchInterval, and outside of the request.
+		if session.LastUsedAt == nil || time.Since(*session.LastUsedAt) > sessionTouchInterval {
+			app.background(func() {
+				err := app.models.Sessions.Touch(session.ID)
+				if err != nil {
+					app.logger.PrintError(err, nil)
+				}
+			})
+		}
+
 		r = app.contextSetUser(r, user)
+		r = app.contextSetSession(r, session)
 
 		next.ServeHTTP(w, r)
 	}
@@ -23,6 +23,7 @@ func (app *application) createProviderHandler(w http.ResponseWriter, r *http.Req
 		Email       string `json:"email"`
 		PhoneNumber string `json:"phone_number"`
 		Description string `json:"description"`
+		Timezone    string `json:"timezone"`
 	}
 
 	err := app.readJSON(w, r, &input)
@@ -38,6 +39,11 @@ func (app *application) createProviderHandler(w http.ResponseWriter, r *http.Req
 		Email:       input.Email,
 		PhoneNumber: input.PhoneNumber,
 		Description: input.Description,
+		Timezone:    input.Timezone,
+	}
+
+	if provider.Timezone == "" {
+		provider.Timezone = "UTC"
 	}
 
 	v := validator.New()
@@ -140,6 +146,24 @@ func (app *application) updateProviderHandler(w http.ResponseWriter, r *http.Req
 
 	v := validator.New()
 
+	if s := app.getFormValue(form, "cancellation_notice_hours"); s != nil {
+		provider.CancellationPolicy.NoticeHours, err = parseOptionalInt(*s)
+		if err != nil {
+			v.AddError("cancellation_notice_hours", "must be an integer value")
+		}
+	}
+
+	if timezone := app.getFormValue(form, "timezone"); timezone != nil {
+		provider.Timezone = *timezone
+	}
+
+	if s := app.getFormValue(form, "max_reschedules"); s != nil {
+		provider.CancellationPolicy.MaxReschedules, err = parseOptionalInt(*s)
+		if err != nil {
+			v.AddError("max_reschedules", "must be an integer value")
+		}
+	}
+
 	if data.ValidateProvider(v, provider); !v.Valid() {
 		app.failedValidationResponse(w, r, v.Errors)
 		return
@@ -291,8 +315,14 @@ func (app *application) createProviderBusinessHours(w http.ResponseWriter, r *ht
 		bh.CloseTime = input.CloseTime
 	}
 
+	existing, err := app.models.ProviderBusinessHours.GetAllForProvider(provider.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
 	v := validator.New()
-	if data.ValidateProviderBusinessHour(v, bh); !v.Valid() {
+	if data.ValidateProviderBusinessHour(v, bh, existing); !v.Valid() {
 		app.failedValidationResponse(w, r, v.Errors)
 		return
 	}
@@ -300,8 +330,8 @@ func (app *application) createProviderBusinessHours(w http.ResponseWriter, r *ht
 	err = app.models.ProviderBusinessHours.Insert(bh)
 	if err != nil {
 		switch {
-		case errors.Is(err, data.ErrDuplicateRecord):
-			v.AddError("provider_id", "business hours already exist for this day")
+		case errors.Is(err, data.ErrOverlappingHours):
+			v.AddError("time", "must not overlap other hours on the same day")
 			app.failedValidationResponse(w, r, v.Errors)
 		default:
 			app.serverErrorResponse(w, r, err)
@@ -341,7 +371,225 @@ func (app *application) listProviderBusinessHours(w http.ResponseWriter, r *http
 		return
 	}
 
-	err = app.writeJSON(w, http.StatusOK, envelope{"business_hours": hours}, nil)
+	err = app.writeJSON(w, http.StatusOK, envelope{"business_hours": data.GroupBusinessHours(hours)}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) replaceProviderBusinessHours(w http.ResponseWriter, r *http.Request) {
+	user := app.contextGetUser(r)
+
+	if user.Role != data.RoleProvider {
+		app.notPermittedResponse(w, r)
+		return
+	}
+
+	provider, err := app.models.Providers.GetByUserID(user.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			msg := "you must setup a provider profile"
+			app.notPermittedWithMessageResponse(w, r, msg)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	var input struct {
+		BusinessHours []struct {
+			DayOfWeek int             `json:"day_of_week"`
+			IsClosed  bool            `json:"is_closed"`
+			OpenTime  *data.LocalTime `json:"open_time"`
+			CloseTime *data.LocalTime `json:"close_time"`
+		} `json:"business_hours"`
+	}
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	hours := make([]*data.ProviderBusinessHour, 0, len(input.BusinessHours))
+	for _, day := range input.BusinessHours {
+		hours = append(hours, &data.ProviderBusinessHour{
+			ProviderID: int(provider.ID),
+			DayOfWeek:  day.DayOfWeek,
+			IsClosed:   day.IsClosed,
+			OpenTime:   day.OpenTime,
+			CloseTime:  day.CloseTime,
+		})
+	}
+
+	v := validator.New()
+	if data.ValidateProviderBusinessWeek(v, hours); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.ProviderBusinessHours.ReplaceDays(provider.ID, hours)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrOverlappingHours):
+			v.AddError("business_hours", "must not contain overlapping hours on the same day")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	hours, err = app.models.ProviderBusinessHours.GetAllForProvider(provider.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"business_hours": data.GroupBusinessHours(hours)}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) updateProviderBusinessHour(w http.ResponseWriter, r *http.Request) {
+	user := app.contextGetUser(r)
+
+	if user.Role != data.RoleProvider {
+		app.notPermittedResponse(w, r)
+		return
+	}
+
+	provider, err := app.models.Providers.GetByUserID(user.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			msg := "you must setup a provider profile"
+			app.notPermittedWithMessageResponse(w, r, msg)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	bh, err := app.models.ProviderBusinessHours.Get(id, provider.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	var input struct {
+		DayOfWeek *int            `json:"day_of_week"`
+		IsClosed  *bool           `json:"is_closed"`
+		OpenTime  *data.LocalTime `json:"open_time"`
+		CloseTime *data.LocalTime `json:"close_time"`
+	}
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if input.DayOfWeek != nil {
+		bh.DayOfWeek = *input.DayOfWeek
+	}
+	if input.IsClosed != nil {
+		bh.IsClosed = *input.IsClosed
+		if bh.IsClosed {
+			bh.OpenTime = nil
+			bh.CloseTime = nil
+		}
+	}
+	if input.OpenTime != nil {
+		bh.OpenTime = input.OpenTime
+	}
+	if input.CloseTime != nil {
+		bh.CloseTime = input.CloseTime
+	}
+
+	existing, err := app.models.ProviderBusinessHours.GetAllForProvider(provider.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+	if data.ValidateProviderBusinessHour(v, bh, existing); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.ProviderBusinessHours.Update(bh)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		case errors.Is(err, data.ErrOverlappingHours):
+			v.AddError("time", "must not overlap other hours on the same day")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"business_hour": bh}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) deleteProviderBusinessHour(w http.ResponseWriter, r *http.Request) {
+	user := app.contextGetUser(r)
+
+	if user.Role != data.RoleProvider {
+		app.notPermittedResponse(w, r)
+		return
+	}
+
+	provider, err := app.models.Providers.GetByUserID(user.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			msg := "you must setup a provider profile"
+			app.notPermittedWithMessageResponse(w, r, msg)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	err = app.models.ProviderBusinessHours.Delete(id, provider.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "business hour successfully deleted"}, nil)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 	}
@@ -17,6 +17,13 @@ func (app *application) routes() *httprouter.Router {
 	router.HandlerFunc(http.MethodPost, "/api/v1/auth/verify-email", app.verifyEmailHandler)
 	router.HandlerFunc(http.MethodPost, "/api/v1/auth/complete-profile", app.completeProfileHandler)
 	router.HandlerFunc(http.MethodPost, "/api/v1/auth/login", app.loginHandler)
+	router.HandlerFunc(http.MethodPost, "/api/v1/auth/refresh", app.refreshTokenHandler)
+	router.HandlerFunc(http.MethodPost, "/api/v1/auth/password-reset", app.createPasswordResetTokenHandler)
+	router.HandlerFunc(http.MethodPut, "/api/v1/auth/password", app.updateUserPasswordHandler)
+	router.HandlerFunc(http.MethodDelete, "/api/v1/auth/session", app.authenticate(app.logoutHandler))
+	router.HandlerFunc(http.MethodGet, "/api/v1/auth/sessions", app.authenticate(app.listSessionsHandler))
+	router.HandlerFunc(http.MethodDelete, "/api/v1/auth/sessions", app.authenticate(app.revokeOtherSessionsHandler))
+	router.HandlerFunc(http.MethodDelete, "/api/v1/auth/sessions/:id", app.authenticate(app.revokeSessionHandler))
 
 	router.HandlerFunc(http.MethodPost, "/api/v1/providers", app.authenticate(app.createProviderHandler))
 	router.HandlerFunc(http.MethodPatch, "/api/v1/providers", app.authenticate(app.updateProviderHandler))
@@ -26,14 +33,86 @@ func (app *application) routes() *httprouter.Router {
 
 	router.HandlerFunc(http.MethodPost, "/api/v1/providers/business-hours", app.authenticate(app.createProviderBusinessHours))
 	router.HandlerFunc(http.MethodGet, "/api/v1/providers/business-hours", app.authenticate(app.listProviderBusinessHours))
+	router.HandlerFunc(http.MethodPut, "/api/v1/providers/business-hours", app.authenticate(app.replaceProviderBusinessHours))
+	router.HandlerFunc(http.MethodPatch, "/api/v1/providers/business-hours/:id", app.authenticate(app.updateProviderBusinessHour))
+	router.HandlerFunc(http.MethodDelete, "/api/v1/providers/business-hours/:id", app.authenticate(app.deleteProviderBusinessHour))
+
+	router.HandlerFunc(http.MethodPost, "/api/v1/providers/hour-exceptions", app.authenticate(app.createHourExceptionHandler))
+	router.HandlerFunc(http.MethodGet, "/api/v1/providers/hour-exceptions", app.authenticate(app.listHourExceptionsHandler))
+	router.HandlerFunc(http.MethodPatch, "/api/v1/providers/hour-exceptions/:id", app.authenticate(app.updateHourExceptionHandler))
+	router.HandlerFunc(http.MethodDelete, "/api/v1/providers/hour-exceptions/:id", app.authenticate(app.deleteHourExceptionHandler))
 
 	router.HandlerFunc(http.MethodPost, "/api/v1/categories", app.authenticate(app.createCategoryHandler))
 
 	router.HandlerFunc(http.MethodPost, "/api/v1/services", app.authenticate(app.createServiceHandler))
 	router.HandlerFunc(http.MethodGet, "/api/v1/services", app.authenticate(app.listServiceHandler))
+	router.HandlerFunc(http.MethodPatch, "/api/v1/services/:id", app.authenticate(app.updateServiceHandler))
+	router.HandlerFunc(http.MethodGet, "/api/v1/services/:id/resources", app.authenticate(app.listServiceResourcesHandler))
+	router.HandlerFunc(http.MethodPut, "/api/v1/services/:id/resources", app.authenticate(app.replaceServiceResourcesHandler))
+
+	router.HandlerFunc(http.MethodPost, "/api/v1/resources", app.authenticate(app.createResourceHandler))
+	router.HandlerFunc(http.MethodGet, "/api/v1/resources", app.authenticate(app.listResourcesHandler))
+	router.HandlerFunc(http.MethodPatch, "/api/v1/resources/:id", app.authenticate(app.updateResourceHandler))
+	router.HandlerFunc(http.MethodDelete, "/api/v1/resources/:id", app.authenticate(app.deleteResourceHandler))
 
 	router.HandlerFunc(http.MethodPost, "/api/v1/staff", app.authenticate(app.createStaffHandler))
 	router.HandlerFunc(http.MethodGet, "/api/v1/staff", app.authenticate(app.listStaffHandler))
 
+	router.HandlerFunc(http.MethodPost, "/api/v1/staff/:id/schedule", app.authenticate(app.createStaffScheduleHandler))
+	router.HandlerFunc(http.MethodGet, "/api/v1/staff/:id/schedule", app.authenticate(app.listStaffScheduleHandler))
+	router.HandlerFunc(http.MethodPatch, "/api/v1/staff/:id/schedule/:schedule_id", app.authenticate(app.updateStaffScheduleHandler))
+	router.HandlerFunc(http.MethodDelete, "/api/v1/staff/:id/schedule/:schedule_id", app.authenticate(app.deleteStaffScheduleHandler))
+
+	router.HandlerFunc(http.MethodPost, "/api/v1/staff/:id/time-off", app.authenticate(app.createStaffTimeOffHandler))
+	router.HandlerFunc(http.MethodGet, "/api/v1/staff/:id/time-off", app.authenticate(app.listStaffTimeOffHandler))
+	router.HandlerFunc(http.MethodPatch, "/api/v1/staff/:id/time-off/:time_off_id", app.authenticate(app.updateStaffTimeOffHandler))
+	router.HandlerFunc(http.MethodDelete, "/api/v1/staff/:id/time-off/:time_off_id", app.authenticate(app.deleteStaffTimeOffHandler))
+
+	router.HandlerFunc(http.MethodPost, "/api/v1/staff/:id/calendar-feed", app.authenticate(app.createStaffCalendarFeedHandler))
+	router.HandlerFunc(http.MethodDelete, "/api/v1/staff/:id/calendar-feed", app.authenticate(app.revokeStaffCalendarFeedHandler))
+
+	router.HandlerFunc(http.MethodPost, "/api/v1/staff/:id/busy-times/import", app.authenticate(app.importBusyTimesHandler))
+	router.HandlerFunc(http.MethodGet, "/api/v1/staff/:id/busy-times", app.authenticate(app.listBusyTimesHandler))
+
+	router.HandlerFunc(http.MethodPost, "/api/v1/staff/:id/calendar-sources", app.authenticate(app.createCalendarSourceHandler))
+	router.HandlerFunc(http.MethodGet, "/api/v1/staff/:id/calendar-sources", app.authenticate(app.listCalendarSourcesHandler))
+	router.HandlerFunc(http.MethodPost, "/api/v1/staff/:id/calendar-sources/:source_id/sync", app.authenticate(app.syncCalendarSourceHandler))
+	router.HandlerFunc(http.MethodDelete, "/api/v1/staff/:id/calendar-sources/:source_id", app.authenticate(app.deleteCalendarSourceHandler))
+
+	router.HandlerFunc(http.MethodPost, "/api/v1/appointments", app.authenticate(app.createAppointmentHandler))
+	router.HandlerFunc(http.MethodGet, "/api/v1/appointments", app.authenticate(app.listAppointmentsHandler))
+	router.HandlerFunc(http.MethodGet, "/api/v1/appointments/:id", app.authenticate(app.showAppointmentHandler))
+	router.HandlerFunc(http.MethodGet, "/api/v1/appointments/:id/history", app.authenticate(app.listAppointmentHistoryHandler))
+	router.HandlerFunc(http.MethodPatch, "/api/v1/appointments/:id/reschedule", app.authenticate(app.rescheduleAppointmentHandler))
+	router.HandlerFunc(http.MethodPatch, "/api/v1/appointments/:id/cancel", app.authenticate(app.cancelAppointmentHandler))
+	router.HandlerFunc(http.MethodPatch, "/api/v1/appointments/:id/complete", app.authenticate(app.completeAppointmentHandler))
+	router.HandlerFunc(http.MethodPatch, "/api/v1/appointments/:id/no-show", app.authenticate(app.noShowAppointmentHandler))
+
+	router.HandlerFunc(http.MethodPost, "/api/v1/appointment-holds", app.authenticate(app.createAppointmentHoldHandler))
+	router.HandlerFunc(http.MethodPatch, "/api/v1/appointment-holds/:id/confirm", app.authenticate(app.confirmAppointmentHoldHandler))
+	router.HandlerFunc(http.MethodDelete, "/api/v1/appointment-holds/:id", app.authenticate(app.releaseAppointmentHoldHandler))
+
+	router.HandlerFunc(http.MethodPost, "/api/v1/appointment-series", app.authenticate(app.createAppointmentSeriesHandler))
+	router.HandlerFunc(http.MethodGet, "/api/v1/appointment-series/:id", app.authenticate(app.showAppointmentSeriesHandler))
+
+	router.HandlerFunc(http.MethodPost, "/api/v1/appointment-groups", app.authenticate(app.createAppointmentGroupHandler))
+	router.HandlerFunc(http.MethodGet, "/api/v1/appointment-groups/:id", app.authenticate(app.showAppointmentGroupHandler))
+
+	router.HandlerFunc(http.MethodPost, "/api/v1/waitlist", app.authenticate(app.createWaitlistEntryHandler))
+	router.HandlerFunc(http.MethodGet, "/api/v1/waitlist", app.authenticate(app.listWaitlistEntriesHandler))
+	router.HandlerFunc(http.MethodDelete, "/api/v1/waitlist/:id", app.authenticate(app.deleteWaitlistEntryHandler))
+	router.HandlerFunc(http.MethodPost, "/api/v1/waitlist/claim", app.authenticate(app.claimWaitlistOfferHandler))
+
+	router.HandlerFunc(http.MethodPost, "/api/v1/walk-ins", app.authenticate(app.createWalkInHandler))
+	router.HandlerFunc(http.MethodGet, "/api/v1/walk-ins", app.authenticate(app.listWalkInQueueHandler))
+	router.HandlerFunc(http.MethodPatch, "/api/v1/walk-ins/:id/status", app.authenticate(app.updateWalkInStatusHandler))
+	router.HandlerFunc(http.MethodGet, "/api/v1/queue/:ticket", app.showQueuePositionHandler)
+
+	router.HandlerFunc(http.MethodGet, "/api/v1/availability", app.authenticate(app.showAvailabilityHandler))
+
+	router.HandlerFunc(http.MethodPost, "/api/v1/calendar-feed", app.authenticate(app.createCalendarFeedHandler))
+	router.HandlerFunc(http.MethodDelete, "/api/v1/calendar-feed", app.authenticate(app.revokeCalendarFeedHandler))
+	router.HandlerFunc(http.MethodGet, "/api/v1/calendar/:token", app.showCalendarFeedHandler)
+
 	return router
 }
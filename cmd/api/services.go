@@ -31,14 +31,17 @@ func (app *application) createServiceHandler(w http.ResponseWriter, r *http.Requ
 	}
 
 	var input struct {
-		Name        string                  `form:"name"`
-		Description string                  `form:"description"`
-		Duration    string                  `form:"duration"`
-		Price       float64                 `form:"price"`
-		TypeID      int32                   `form:"type_id"`
-		CategoryIDs []int32                 `form:"categories"`
-		StaffIDs    []int64                 `form:"staff"`
-		Images      []*multipart.FileHeader `form:"images"`
+		Name         string                  `form:"name"`
+		Description  string                  `form:"description"`
+		Duration     string                  `form:"duration"`
+		BufferBefore string                  `form:"buffer_before"`
+		BufferAfter  string                  `form:"buffer_after"`
+		Capacity     *int                    `form:"capacity"`
+		Price        float64                 `form:"price"`
+		TypeID       int32                   `form:"type_id"`
+		CategoryIDs  []int32                 `form:"categories"`
+		StaffIDs     []int64                 `form:"staff"`
+		Images       []*multipart.FileHeader `form:"images"`
 	}
 
 	err = app.readMultipartForm(r, 10<<20, &input)
@@ -48,14 +51,21 @@ func (app *application) createServiceHandler(w http.ResponseWriter, r *http.Requ
 	}
 
 	service := &data.Service{
-		Name:        input.Name,
-		ProviderID:  provider.ID,
-		TypeID:      input.TypeID,
-		Categories:  input.CategoryIDs,
-		Description: input.Description,
-		Duration:    input.Duration,
-		Price:       input.Price,
-		Staff:       input.StaffIDs,
+		Name:         input.Name,
+		ProviderID:   provider.ID,
+		TypeID:       input.TypeID,
+		Categories:   input.CategoryIDs,
+		Description:  input.Description,
+		Duration:     input.Duration,
+		BufferBefore: input.BufferBefore,
+		BufferAfter:  input.BufferAfter,
+		Price:        input.Price,
+		Staff:        input.StaffIDs,
+		Capacity:     1,
+	}
+
+	if input.Capacity != nil {
+		service.Capacity = *input.Capacity
 	}
 
 	v := validator.New()
@@ -163,3 +173,109 @@ func (app *application) listServiceHandler(w http.ResponseWriter, r *http.Reques
 		app.serverErrorResponse(w, r, err)
 	}
 }
+
+func (app *application) updateServiceHandler(w http.ResponseWriter, r *http.Request) {
+	user := app.contextGetUser(r)
+
+	if user.Role != data.RoleProvider {
+		app.notPermittedResponse(w, r)
+		return
+	}
+
+	provider, err := app.models.Providers.GetByUserID(user.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			msg := "you must setup a provider profile"
+			app.notPermittedWithMessageResponse(w, r, msg)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	service, err := app.models.Services.Get(id, provider.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	var input struct {
+		Name         *string  `json:"name"`
+		Description  *string  `json:"description"`
+		Duration     *string  `json:"duration"`
+		BufferBefore *string  `json:"buffer_before"`
+		BufferAfter  *string  `json:"buffer_after"`
+		Capacity     *int     `json:"capacity"`
+		Price        *float64 `json:"price"`
+		TypeID       *int32   `json:"type_id"`
+	}
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if input.Name != nil {
+		service.Name = *input.Name
+	}
+	if input.Description != nil {
+		service.Description = *input.Description
+	}
+	if input.Duration != nil {
+		service.Duration = *input.Duration
+	}
+	if input.BufferBefore != nil {
+		service.BufferBefore = *input.BufferBefore
+	}
+	if input.BufferAfter != nil {
+		service.BufferAfter = *input.BufferAfter
+	}
+	if input.Capacity != nil {
+		service.Capacity = *input.Capacity
+	}
+	if input.Price != nil {
+		service.Price = *input.Price
+	}
+	if input.TypeID != nil {
+		service.TypeID = *input.TypeID
+	}
+
+	v := validator.New()
+
+	if data.ValidateServiceDetails(v, service); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Services.Update(service)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrDuplicateRecord):
+			v.AddError("service", "a service with that name already exists for this provider")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"service": service}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
@@ -0,0 +1,407 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/tormgibbs/snapluks-backend/internal/data"
+	"github.com/tormgibbs/snapluks-backend/internal/validator"
+)
+
+// staffForProvider loads the staff member named in the URL and checks that it
+// belongs to the authenticated provider. It writes the error response itself
+// and returns false when the handler should stop.
+func (app *application) staffForProvider(w http.ResponseWriter, r *http.Request) (*data.Staff, bool) {
+	user := app.contextGetUser(r)
+
+	if user.Role != data.RoleProvider {
+		app.notPermittedResponse(w, r)
+		return nil, false
+	}
+
+	provider, err := app.models.Providers.GetByUserID(user.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			msg := "you must setup a provider profile"
+			app.notPermittedWithMessageResponse(w, r, msg)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return nil, false
+	}
+
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return nil, false
+	}
+
+	staff, err := app.models.Staff.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return nil, false
+	}
+
+	if staff.ProviderID != provider.ID {
+		app.notFoundResponse(w, r)
+		return nil, false
+	}
+
+	return staff, true
+}
+
+func (app *application) createStaffScheduleHandler(w http.ResponseWriter, r *http.Request) {
+	staff, ok := app.staffForProvider(w, r)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		DayOfWeek int             `json:"day_of_week"`
+		StartTime *data.LocalTime `json:"start_time"`
+		EndTime   *data.LocalTime `json:"end_time"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	schedule := &data.StaffSchedule{
+		StaffID:    staff.ID,
+		ProviderID: staff.ProviderID,
+		DayOfWeek:  input.DayOfWeek,
+		StartTime:  input.StartTime,
+		EndTime:    input.EndTime,
+	}
+
+	existing, err := app.models.StaffSchedules.GetAllForStaff(staff.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+
+	if data.ValidateStaffSchedule(v, schedule, existing); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.StaffSchedules.Insert(schedule)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrOutsideBusinessHours):
+			v.AddError("time", "must fall within the provider's business hours for that day")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, data.ErrOverlappingHours):
+			v.AddError("time", "must not overlap another shift on the same day")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"schedule": schedule}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) listStaffScheduleHandler(w http.ResponseWriter, r *http.Request) {
+	staff, ok := app.staffForProvider(w, r)
+	if !ok {
+		return
+	}
+
+	schedules, err := app.models.StaffSchedules.GetAllForStaff(staff.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"schedule": schedules}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) updateStaffScheduleHandler(w http.ResponseWriter, r *http.Request) {
+	staff, ok := app.staffForProvider(w, r)
+	if !ok {
+		return
+	}
+
+	scheduleID, err := app.readNamedIDParam(r, "schedule_id")
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	schedule, err := app.models.StaffSchedules.Get(scheduleID, staff.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	var input struct {
+		DayOfWeek *int            `json:"day_of_week"`
+		StartTime *data.LocalTime `json:"start_time"`
+		EndTime   *data.LocalTime `json:"end_time"`
+	}
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if input.DayOfWeek != nil {
+		schedule.DayOfWeek = *input.DayOfWeek
+	}
+	if input.StartTime != nil {
+		schedule.StartTime = input.StartTime
+	}
+	if input.EndTime != nil {
+		schedule.EndTime = input.EndTime
+	}
+
+	existing, err := app.models.StaffSchedules.GetAllForStaff(staff.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+
+	if data.ValidateStaffSchedule(v, schedule, existing); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.StaffSchedules.Update(schedule)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		case errors.Is(err, data.ErrOutsideBusinessHours):
+			v.AddError("time", "must fall within the provider's business hours for that day")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, data.ErrOverlappingHours):
+			v.AddError("time", "must not overlap another shift on the same day")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"schedule": schedule}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) deleteStaffScheduleHandler(w http.ResponseWriter, r *http.Request) {
+	staff, ok := app.staffForProvider(w, r)
+	if !ok {
+		return
+	}
+
+	scheduleID, err := app.readNamedIDParam(r, "schedule_id")
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	err = app.models.StaffSchedules.Delete(scheduleID, staff.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "schedule successfully deleted"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) createStaffTimeOffHandler(w http.ResponseWriter, r *http.Request) {
+	staff, ok := app.staffForProvider(w, r)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		StartTime time.Time `json:"start_time"`
+		EndTime   time.Time `json:"end_time"`
+		Reason    *string   `json:"reason"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	timeOff := &data.StaffTimeOff{
+		StaffID:    staff.ID,
+		ProviderID: staff.ProviderID,
+		StartTime:  input.StartTime,
+		EndTime:    input.EndTime,
+		Reason:     input.Reason,
+	}
+
+	v := validator.New()
+
+	if data.ValidateStaffTimeOff(v, timeOff); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.StaffTimeOff.Insert(timeOff)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"time_off": timeOff}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) listStaffTimeOffHandler(w http.ResponseWriter, r *http.Request) {
+	staff, ok := app.staffForProvider(w, r)
+	if !ok {
+		return
+	}
+
+	timeOff, err := app.models.StaffTimeOff.GetAllForStaff(staff.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"time_off": timeOff}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) updateStaffTimeOffHandler(w http.ResponseWriter, r *http.Request) {
+	staff, ok := app.staffForProvider(w, r)
+	if !ok {
+		return
+	}
+
+	timeOffID, err := app.readNamedIDParam(r, "time_off_id")
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	timeOff, err := app.models.StaffTimeOff.Get(timeOffID, staff.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	var input struct {
+		StartTime *time.Time `json:"start_time"`
+		EndTime   *time.Time `json:"end_time"`
+		Reason    *string    `json:"reason"`
+	}
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if input.StartTime != nil {
+		timeOff.StartTime = *input.StartTime
+	}
+	if input.EndTime != nil {
+		timeOff.EndTime = *input.EndTime
+	}
+	if input.Reason != nil {
+		timeOff.Reason = input.Reason
+	}
+
+	v := validator.New()
+
+	if data.ValidateStaffTimeOff(v, timeOff); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.StaffTimeOff.Update(timeOff)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"time_off": timeOff}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) deleteStaffTimeOffHandler(w http.ResponseWriter, r *http.Request) {
+	staff, ok := app.staffForProvider(w, r)
+	if !ok {
+		return
+	}
+
+	timeOffID, err := app.readNamedIDParam(r, "time_off_id")
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	err = app.models.StaffTimeOff.Delete(timeOffID, staff.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "time off successfully deleted"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
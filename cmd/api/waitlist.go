@@ -0,0 +1,204 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/tormgibbs/snapluks-backend/internal/data"
+	"github.com/tormgibbs/snapluks-backend/internal/validator"
+)
+
+// waitlistClaimTTL is how long a freed slot is held for a waitlisted client.
+const waitlistClaimTTL = 2 * time.Hour
+
+func (app *application) createWaitlistEntryHandler(w http.ResponseWriter, r *http.Request) {
+	user := app.contextGetUser(r)
+
+	if user.Role != data.RoleClient {
+		app.notPermittedResponse(w, r)
+		return
+	}
+
+	var input struct {
+		ProviderID int64     `json:"provider_id"`
+		ServiceID  int64     `json:"service_id"`
+		StaffID    *int64    `json:"staff_id"`
+		StartDate  data.Date `json:"start_date"`
+		EndDate    data.Date `json:"end_date"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	entry := &data.WaitlistEntry{
+		ProviderID: input.ProviderID,
+		ServiceID:  input.ServiceID,
+		StaffID:    input.StaffID,
+		ClientID:   user.ID,
+		StartDate:  input.StartDate,
+		EndDate:    input.EndDate,
+	}
+
+	v := validator.New()
+
+	if data.ValidateWaitlistEntry(v, entry); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Waitlist.Insert(entry)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrServiceNotFound):
+			v.AddError("service_id", "service not found")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, data.ErrStaffServiceMismatch):
+			v.AddError("staff_id", "the selected staff member does not offer this service")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"waitlist_entry": entry}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) listWaitlistEntriesHandler(w http.ResponseWriter, r *http.Request) {
+	user := app.contextGetUser(r)
+
+	if user.Role != data.RoleClient {
+		app.notPermittedResponse(w, r)
+		return
+	}
+
+	entries, err := app.models.Waitlist.GetAllForClient(user.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"waitlist_entries": entries}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) deleteWaitlistEntryHandler(w http.ResponseWriter, r *http.Request) {
+	user := app.contextGetUser(r)
+
+	if user.Role != data.RoleClient {
+		app.notPermittedResponse(w, r)
+		return
+	}
+
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	err = app.models.Waitlist.Delete(id, user.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "waitlist entry successfully deleted"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) claimWaitlistOfferHandler(w http.ResponseWriter, r *http.Request) {
+	user := app.contextGetUser(r)
+
+	if user.Role != data.RoleClient {
+		app.notPermittedResponse(w, r)
+		return
+	}
+
+	var input struct {
+		TokenPlaintext string `json:"token"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+
+	if data.ValidateTokenPlaintext(v, input.TokenPlaintext, data.ScopeWaitlistClaim); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	appointment, err := app.models.Waitlist.Claim(input.TokenPlaintext, user.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrInvalidClaimToken):
+			v.AddError("token", "invalid or expired claim token")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, data.ErrSlotUnavailable),
+			errors.Is(err, data.ErrOutsideBusinessHours),
+			errors.Is(err, data.ErrStaffUnavailable):
+			app.slotUnavailableResponse(w, r)
+		case errors.Is(err, data.ErrResourceUnavailable):
+			app.resourceUnavailableResponse(w, r)
+		case errors.Is(err, data.ErrSessionFull):
+			app.sessionFullResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"appointment": appointment}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// notifyWaitlist offers the slots freed by the cancelled appointments to the
+// waitlist in the background and emails each client a claim token. Each slot
+// goes to the first waiting client who can take it, one client at a time.
+func (app *application) notifyWaitlist(appointmentIDs ...int64) {
+	app.background(func() {
+		for _, id := range appointmentIDs {
+			offer, err := app.models.Waitlist.OfferSlot(id, waitlistClaimTTL)
+			if err != nil {
+				app.logger.PrintError(err, nil)
+				continue
+			}
+
+			if offer == nil {
+				continue
+			}
+
+			data := map[string]any{
+				"claimToken": offer.Token.Plaintext,
+				"startTime":  offer.StartTime.Format("Monday, 2 January 2006 at 15:04 MST"),
+				"expiry":     offer.Expiry.In(offer.StartTime.Location()).Format("15:04 MST on 2 January"),
+			}
+
+			err = app.mailer.SendMail(offer.ClientEmail, "waitlist_offer.tmpl", data)
+			if err != nil {
+				app.logger.PrintError(err, nil)
+			}
+		}
+	})
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/tormgibbs/snapluks-backend/internal/data"
+	"github.com/tormgibbs/snapluks-backend/internal/validator"
+)
+
+func (app *application) createWalkInHandler(w http.ResponseWriter, r *http.Request) {
+	user := app.contextGetUser(r)
+
+	if user.Role != data.RoleProvider {
+		app.notPermittedResponse(w, r)
+		return
+	}
+
+	provider, err := app.models.Providers.GetByUserID(user.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			msg := "you must setup a provider profile"
+			app.notPermittedWithMessageResponse(w, r, msg)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	var input struct {
+		ServiceID   int64   `json:"service_id"`
+		StaffID     *int64  `json:"staff_id"`
+		ClientID    *int64  `json:"client_id"`
+		Name        *string `json:"name"`
+		PhoneNumber *string `json:"phone_number"`
+	}
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	walkIn := &data.WalkIn{
+		ProviderID:  provider.ID,
+		ServiceID:   input.ServiceID,
+		StaffID:     input.StaffID,
+		ClientID:    input.ClientID,
+		Name:        input.Name,
+		PhoneNumber: input.PhoneNumber,
+	}
+
+	v := validator.New()
+
+	if data.ValidateWalkIn(v, walkIn); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.WalkIns.Insert(walkIn)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrServiceNotFound):
+			v.AddError("service_id", "service not found")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, data.ErrStaffServiceMismatch):
+			v.AddError("staff_id", "the selected staff member does not offer this service")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, data.ErrClientNotFound):
+			v.AddError("client_id", "client not found")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	walkIn, err = app.models.WalkIns.GetByTicket(walkIn.Ticket)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"walk_in": walkIn}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) listWalkInQueueHandler(w http.ResponseWriter, r *http.Request) {
+	user := app.contextGetUser(r)
+
+	if user.Role != data.RoleProvider {
+		app.notPermittedResponse(w, r)
+		return
+	}
+
+	provider, err := app.models.Providers.GetByUserID(user.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			msg := "you must setup a provider profile"
+			app.notPermittedWithMessageResponse(w, r, msg)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	queue, err := app.models.WalkIns.GetQueue(provider.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"queue": queue}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) updateWalkInStatusHandler(w http.ResponseWriter, r *http.Request) {
+	user := app.contextGetUser(r)
+
+	if user.Role != data.RoleProvider {
+		app.notPermittedResponse(w, r)
+		return
+	}
+
+	provider, err := app.models.Providers.GetByUserID(user.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			msg := "you must setup a provider profile"
+			app.notPermittedWithMessageResponse(w, r, msg)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	walkIn, err := app.models.WalkIns.Get(id, provider.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	var input struct {
+		Status  string `json:"status"`
+		StaffID *int64 `json:"staff_id"`
+	}
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+
+	v.Check(validator.In(input.Status, data.WalkInServing, data.WalkInServed, data.WalkInLeft), "status", "must be one of serving, served or left")
+
+	if input.Status == data.WalkInServing {
+		if input.StaffID == nil {
+			input.StaffID = walkIn.StaffID
+		}
+		v.Check(input.StaffID != nil, "staff_id", "must be provided when serving a walk-in")
+	} else {
+		v.Check(input.StaffID == nil, "staff_id", "can only be set when serving a walk-in")
+	}
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.WalkIns.UpdateStatus(walkIn, input.Status, input.StaffID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrInvalidTransition):
+			v.AddError("status", fmt.Sprintf("cannot change a %s walk-in to %s", walkIn.Status, input.Status))
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, data.ErrStaffServiceMismatch):
+			v.AddError("staff_id", "the selected staff member does not offer this service")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"walk_in": walkIn}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// showQueuePositionHandler lets a walk-in check their place in the queue with
+// the ticket they were given, without needing an account.
+func (app *application) showQueuePositionHandler(w http.ResponseWriter, r *http.Request) {
+	ticket := httprouter.ParamsFromContext(r.Context()).ByName("ticket")
+
+	walkIn, err := app.models.WalkIns.GetByTicket(ticket)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"walk_in": walkIn}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
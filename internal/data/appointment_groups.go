@@ -0,0 +1,235 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/tormgibbs/snapluks-backend/internal/validator"
+)
+
+// maxGroupItems caps the number of services booked in one visit.
+const maxGroupItems = 5
+
+type AppointmentGroupModel struct {
+	DB *sql.DB
+}
+
+// AppointmentGroup is a single visit made up of several services booked back
+// to back, each as its own appointment.
+type AppointmentGroup struct {
+	ID           int64          `json:"id"`
+	ProviderID   int64          `json:"provider_id"`
+	ClientID     int64          `json:"client_id"`
+	StartTime    time.Time      `json:"start_time"`
+	EndTime      time.Time      `json:"end_time"`
+	TotalPrice   float64        `json:"total_price"`
+	CreatedAt    time.Time      `json:"created_at"`
+	Appointments []*Appointment `json:"appointments"`
+}
+
+// GroupItem is one service of a group booking and the staff member who will
+// perform it.
+type GroupItem struct {
+	ServiceID int64 `json:"service_id"`
+	StaffID   int64 `json:"staff_id"`
+}
+
+// GroupItemError reports which item of a group booking could not be booked.
+type GroupItemError struct {
+	Index int
+	Err   error
+}
+
+func (e *GroupItemError) Error() string {
+	return fmt.Sprintf("item %d: %s", e.Index, e.Err)
+}
+
+func (e *GroupItemError) Unwrap() error {
+	return e.Err
+}
+
+func ValidateGroupItems(v *validator.Validator, items []GroupItem) {
+	v.Check(len(items) >= 2, "items", "must contain at least two services")
+	v.Check(len(items) <= maxGroupItems, "items", "must not contain more than 5 services")
+
+	for i, item := range items {
+		v.Check(item.ServiceID > 0, fmt.Sprintf("items[%d].service_id", i), "must be provided and greater than zero")
+		v.Check(item.StaffID > 0, fmt.Sprintf("items[%d].staff_id", i), "must be provided and greater than zero")
+	}
+}
+
+// servicePrice looks up the price of a service offered by the provider.
+func servicePrice(ctx context.Context, q queryer, providerID, serviceID int64) (float64, error) {
+	query := `
+		SELECT price
+		FROM services
+		WHERE id = $1 AND provider_id = $2
+	`
+
+	var price float64
+
+	err := q.QueryRowContext(ctx, query, serviceID, providerID).Scan(&price)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return 0, ErrServiceNotFound
+		default:
+			return 0, err
+		}
+	}
+
+	return price, nil
+}
+
+// Insert books the items one after the other from the group's start time, in
+// a single transaction. An item performed by the same staff member as the one
+// before it starts once both of their buffers have passed. Every item must
+// belong to the same provider, and the whole group fails if any item cannot be
+// booked. Such failures are wrapped in a GroupItemError.
+func (m AppointmentGroupModel) Insert(g *AppointmentGroup, items []GroupItem) (err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		} else if err != nil {
+			tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	g.EndTime = g.StartTime
+	g.TotalPrice = 0
+
+	starts := make([]time.Time, len(items))
+	timings := make([]timing, len(items))
+
+	for i, item := range items {
+		providerID, err := staffServiceProvider(ctx, tx, item.StaffID, item.ServiceID)
+		if err != nil {
+			return &GroupItemError{Index: i, Err: err}
+		}
+
+		if i == 0 {
+			g.ProviderID = providerID
+		} else if providerID != g.ProviderID {
+			return &GroupItemError{Index: i, Err: ErrStaffServiceMismatch}
+		}
+
+		timings[i], err = serviceTiming(ctx, tx, providerID, item.ServiceID)
+		if err != nil {
+			return &GroupItemError{Index: i, Err: err}
+		}
+
+		price, err := servicePrice(ctx, tx, providerID, item.ServiceID)
+		if err != nil {
+			return &GroupItemError{Index: i, Err: err}
+		}
+
+		if i > 0 && item.StaffID == items[i-1].StaffID {
+			g.EndTime = g.EndTime.Add(timings[i-1].After + timings[i].Before)
+		}
+
+		starts[i] = g.EndTime
+		g.EndTime = g.EndTime.Add(timings[i].Duration)
+		g.TotalPrice += price
+	}
+
+	query := `
+		INSERT INTO appointment_groups (provider_id, client_id, start_time, end_time, total_price)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at
+	`
+
+	args := []any{
+		g.ProviderID,
+		g.ClientID,
+		g.StartTime,
+		g.EndTime,
+		g.TotalPrice,
+	}
+
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&g.ID, &g.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	g.Appointments = make([]*Appointment, 0, len(items))
+
+	for i, item := range items {
+		a := &Appointment{
+			ProviderID: g.ProviderID,
+			ServiceID:  item.ServiceID,
+			StaffID:    item.StaffID,
+			ClientID:   g.ClientID,
+			GroupID:    &g.ID,
+			StartTime:  starts[i],
+		}
+
+		err = insertAppointment(ctx, tx, a)
+		if err != nil {
+			return &GroupItemError{Index: i, Err: err}
+		}
+
+		g.Appointments = append(g.Appointments, a)
+	}
+
+	loc := g.Appointments[0].StartTime.Location()
+	g.StartTime = g.StartTime.In(loc)
+	g.EndTime = g.EndTime.In(loc)
+
+	return nil
+}
+
+func (m AppointmentGroupModel) Get(id int64) (*AppointmentGroup, error) {
+	query := `
+		SELECT id, provider_id, client_id, start_time, end_time, total_price, created_at
+		FROM appointment_groups
+		WHERE id = $1
+	`
+
+	var g AppointmentGroup
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(
+		&g.ID,
+		&g.ProviderID,
+		&g.ClientID,
+		&g.StartTime,
+		&g.EndTime,
+		&g.TotalPrice,
+		&g.CreatedAt,
+	)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	loc, err := providerLocation(ctx, m.DB, g.ProviderID)
+	if err != nil {
+		return nil, err
+	}
+
+	g.StartTime = g.StartTime.In(loc)
+	g.EndTime = g.EndTime.In(loc)
+
+	return &g, nil
+}
@@ -0,0 +1,171 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// Hold books the appointment as held until ttl from now. A held appointment
+// takes its staff member's time, its resources and its seat in a class like a
+// confirmed one, but is released unless the client confirms it before the hold
+// expires.
+func (m AppointmentModel) Hold(a *Appointment, ttl time.Duration) (err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		} else if err != nil {
+			tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	a.ProviderID, err = staffServiceProvider(ctx, tx, a.StaffID, a.ServiceID)
+	if err != nil {
+		return err
+	}
+
+	expiry := time.Now().Add(ttl).Truncate(time.Second)
+
+	a.Status = AppointmentHeld
+	a.HoldExpiresAt = &expiry
+
+	return insertAppointment(ctx, tx, a)
+}
+
+// ConfirmHold turns the held appointment into a confirmed one. The row is
+// locked first so that the hold cannot be released while it is confirmed. It
+// returns ErrInvalidTransition if the appointment is not held, and
+// ErrHoldExpired if the hold has run out, even when it has not been released
+// yet.
+func (m AppointmentModel) ConfirmHold(a *Appointment) (err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		} else if err != nil {
+			tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	query := `
+		SELECT status, hold_expires_at <= NOW()
+		FROM appointments
+		WHERE id = $1
+		FOR UPDATE
+	`
+
+	var (
+		status  AppointmentStatus
+		expired sql.NullBool
+	)
+
+	err = tx.QueryRowContext(ctx, query, a.ID).Scan(&status, &expired)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+
+	if status != AppointmentHeld {
+		a.Status = status
+		return ErrInvalidTransition
+	}
+
+	if expired.Bool {
+		return ErrHoldExpired
+	}
+
+	query = `
+		UPDATE appointments
+		SET status = 'confirmed', hold_expires_at = NULL
+		WHERE id = $1
+	`
+
+	_, err = tx.ExecContext(ctx, query, a.ID)
+	if err != nil {
+		return err
+	}
+
+	a.Status = AppointmentConfirmed
+	a.HoldExpiresAt = nil
+
+	return nil
+}
+
+// ReleaseHold deletes the held appointment, freeing its slot straight away. It
+// returns ErrRecordNotFound if the appointment is no longer held.
+func (m AppointmentModel) ReleaseHold(id int64) error {
+	query := `
+		DELETE FROM appointments
+		WHERE id = $1 AND status = 'held'
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// ReleaseExpiredHolds deletes every hold that has run out and returns how many
+// were released.
+func (m AppointmentModel) ReleaseExpiredHolds() (int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return releaseExpiredHolds(ctx, m.DB)
+}
+
+// releaseExpiredHolds deletes the holds that have run out. Their resource
+// allocations go with them.
+func releaseExpiredHolds(ctx context.Context, q queryer) (int64, error) {
+	query := `
+		DELETE FROM appointments
+		WHERE hold_expires_at <= NOW()
+	`
+
+	result, err := q.ExecContext(ctx, query)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
@@ -0,0 +1,83 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+type AppointmentRescheduleModel struct {
+	DB *sql.DB
+}
+
+// AppointmentReschedule records where an appointment was before it was moved.
+type AppointmentReschedule struct {
+	ID                int64     `json:"id"`
+	AppointmentID     int64     `json:"appointment_id"`
+	PreviousStaffID   int64     `json:"previous_staff_id"`
+	PreviousStartTime time.Time `json:"previous_start_time"`
+	PreviousEndTime   time.Time `json:"previous_end_time"`
+	RescheduledBy     *int64    `json:"rescheduled_by"`
+	RescheduledAt     time.Time `json:"rescheduled_at"`
+}
+
+func insertReschedule(ctx context.Context, q queryer, rs *AppointmentReschedule) error {
+	query := `
+		INSERT INTO appointment_reschedules (appointment_id, previous_staff_id, previous_start_time, previous_end_time, rescheduled_by)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, rescheduled_at
+	`
+
+	args := []any{
+		rs.AppointmentID,
+		rs.PreviousStaffID,
+		rs.PreviousStartTime,
+		rs.PreviousEndTime,
+		rs.RescheduledBy,
+	}
+
+	return q.QueryRowContext(ctx, query, args...).Scan(&rs.ID, &rs.RescheduledAt)
+}
+
+func (m AppointmentRescheduleModel) GetAllForAppointment(appointmentID int64) ([]*AppointmentReschedule, error) {
+	query := `
+		SELECT id, appointment_id, previous_staff_id, previous_start_time, previous_end_time, rescheduled_by, rescheduled_at
+		FROM appointment_reschedules
+		WHERE appointment_id = $1
+		ORDER BY rescheduled_at, id
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, appointmentID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	reschedules := make([]*AppointmentReschedule, 0)
+
+	for rows.Next() {
+		var rs AppointmentReschedule
+		err := rows.Scan(
+			&rs.ID,
+			&rs.AppointmentID,
+			&rs.PreviousStaffID,
+			&rs.PreviousStartTime,
+			&rs.PreviousEndTime,
+			&rs.RescheduledBy,
+			&rs.RescheduledAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		reschedules = append(reschedules, &rs)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return reschedules, nil
+}
@@ -0,0 +1,506 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/tormgibbs/snapluks-backend/internal/validator"
+)
+
+// maxOccurrences caps the number of appointments a single series can create.
+const maxOccurrences = 52
+
+// occurrenceTimeout is the time allowed for each occurrence that a series
+// operation books or changes, on top of the usual three seconds. Each one runs
+// the same checks as a single booking, so a fixed timeout would be too short
+// for a long series.
+const occurrenceTimeout = 250 * time.Millisecond
+
+// seriesTimeout returns the timeout of a series operation that books or
+// changes the given number of occurrences.
+func seriesTimeout(occurrences int) time.Duration {
+	return 3*time.Second + time.Duration(occurrences)*occurrenceTimeout
+}
+
+var (
+	ErrSeriesUnavailable = errors.New("no occurrence of the series could be booked")
+	ErrNotInSeries       = errors.New("appointment is not part of a series")
+)
+
+const (
+	FrequencyWeekly   = "weekly"
+	FrequencyBiweekly = "biweekly"
+	FrequencyMonthly  = "monthly"
+)
+
+var Frequencies = []string{FrequencyWeekly, FrequencyBiweekly, FrequencyMonthly}
+
+// RecurrenceRule is a small subset of an iCalendar RRULE: a frequency bounded
+// either by a number of occurrences or by a last date.
+type RecurrenceRule struct {
+	Frequency string `json:"frequency"`
+	Count     *int   `json:"count,omitempty"`
+	Until     *Date  `json:"until,omitempty"`
+}
+
+func ValidateRecurrenceRule(v *validator.Validator, rule RecurrenceRule, start time.Time) {
+	v.Check(validator.In(rule.Frequency, Frequencies...), "frequency", "must be one of weekly, biweekly or monthly")
+	v.Check((rule.Count == nil) != (rule.Until == nil), "recurrence", "must have either a count or an until date")
+
+	if rule.Count != nil {
+		v.Check(*rule.Count >= 2, "count", "must be at least 2")
+		v.Check(*rule.Count <= maxOccurrences, "count", "must not be more than 52")
+	}
+
+	if rule.Until != nil {
+		v.Check(rule.Until.After(DateOf(start).Time), "until", "must be after the first occurrence")
+		v.Check(rule.Until.Before(start.AddDate(1, 0, 1)), "until", "must not be more than a year after the first occurrence")
+	}
+}
+
+// Occurrences returns the start times of every occurrence of the rule, the
+// first being start itself. Each occurrence keeps the wall clock time of start
+// in its location, so a series keeps its local time across DST changes.
+// Monthly occurrences on a day that a month lacks, such as the 31st, are
+// skipped as they are in iCalendar.
+func (rule RecurrenceRule) Occurrences(start time.Time) []time.Time {
+	var occurrences []time.Time
+
+	for i := 0; len(occurrences) < maxOccurrences; i++ {
+		var t time.Time
+
+		switch rule.Frequency {
+		case FrequencyWeekly:
+			t = time.Date(start.Year(), start.Month(), start.Day()+7*i, start.Hour(), start.Minute(), start.Second(), 0, start.Location())
+		case FrequencyBiweekly:
+			t = time.Date(start.Year(), start.Month(), start.Day()+14*i, start.Hour(), start.Minute(), start.Second(), 0, start.Location())
+		case FrequencyMonthly:
+			t = time.Date(start.Year(), start.Month()+time.Month(i), start.Day(), start.Hour(), start.Minute(), start.Second(), 0, start.Location())
+			if t.Day() != start.Day() {
+				continue
+			}
+		default:
+			return occurrences
+		}
+
+		if rule.Until != nil && DateOf(t).After(rule.Until.Time) {
+			break
+		}
+
+		occurrences = append(occurrences, t)
+
+		if rule.Count != nil && len(occurrences) >= *rule.Count {
+			break
+		}
+	}
+
+	return occurrences
+}
+
+type AppointmentSeriesModel struct {
+	DB *sql.DB
+}
+
+type AppointmentSeries struct {
+	ID         int64          `json:"id"`
+	ProviderID int64          `json:"provider_id"`
+	ServiceID  int64          `json:"service_id"`
+	StaffID    int64          `json:"staff_id"`
+	ClientID   int64          `json:"client_id"`
+	StartTime  time.Time      `json:"start_time"`
+	Recurrence RecurrenceRule `json:"recurrence"`
+	CreatedAt  time.Time      `json:"created_at"`
+}
+
+// SkippedOccurrence is an occurrence of a series that could not be booked or
+// changed, together with a short machine-readable reason.
+type SkippedOccurrence struct {
+	AppointmentID *int64    `json:"appointment_id,omitempty"`
+	StartTime     time.Time `json:"start_time"`
+	Reason        string    `json:"reason"`
+}
+
+// occurrenceFailure maps the errors that make a single occurrence fail to the
+// reason reported for it. Any other error aborts the whole operation.
+func occurrenceFailure(err error) (string, bool) {
+	switch {
+	case errors.Is(err, ErrSlotUnavailable):
+		return "slot_unavailable", true
+	case errors.Is(err, ErrOutsideBusinessHours):
+		return "outside_business_hours", true
+	case errors.Is(err, ErrStaffUnavailable):
+		return "staff_unavailable", true
+	case errors.Is(err, ErrResourceUnavailable):
+		return "resource_unavailable", true
+	case errors.Is(err, ErrSessionFull):
+		return "session_full", true
+	case errors.Is(err, ErrRescheduleLimit):
+		return "reschedule_limit", true
+	case errors.Is(err, ErrCancellationNotice):
+		return "within_notice_period", true
+	default:
+		return "", false
+	}
+}
+
+// savepoint runs fn inside a savepoint of the transaction, so that a failed
+// occurrence is rolled back without losing the ones already written.
+func savepoint(ctx context.Context, tx *sql.Tx, fn func() error) error {
+	_, err := tx.ExecContext(ctx, "SAVEPOINT occurrence")
+	if err != nil {
+		return err
+	}
+
+	err = fn()
+	if err != nil {
+		_, rbErr := tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT occurrence")
+		if rbErr != nil {
+			return rbErr
+		}
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, "RELEASE SAVEPOINT occurrence")
+	return err
+}
+
+// Insert creates the series and books each of its occurrences. Occurrences
+// that clash with other bookings or fall outside working hours are skipped and
+// returned, while the rest are booked. If none can be booked nothing is saved
+// and ErrSeriesUnavailable is returned.
+func (m AppointmentSeriesModel) Insert(s *AppointmentSeries) (booked []*Appointment, skipped []*SkippedOccurrence, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), seriesTimeout(len(s.Recurrence.Occurrences(s.StartTime))))
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		} else if err != nil {
+			tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	s.ProviderID, err = staffServiceProvider(ctx, tx, s.StaffID, s.ServiceID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	loc, err := providerLocation(ctx, tx, s.ProviderID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	s.StartTime = s.StartTime.In(loc)
+
+	query := `
+		INSERT INTO appointment_series (provider_id, service_id, staff_id, client_id, frequency, occurrence_count, until_date, start_time)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id, created_at
+	`
+
+	args := []any{
+		s.ProviderID,
+		s.ServiceID,
+		s.StaffID,
+		s.ClientID,
+		s.Recurrence.Frequency,
+		s.Recurrence.Count,
+		s.Recurrence.Until,
+		s.StartTime,
+	}
+
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&s.ID, &s.CreatedAt)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	booked = make([]*Appointment, 0)
+	skipped = make([]*SkippedOccurrence, 0)
+
+	for _, start := range s.Recurrence.Occurrences(s.StartTime) {
+		a := &Appointment{
+			ProviderID: s.ProviderID,
+			ServiceID:  s.ServiceID,
+			StaffID:    s.StaffID,
+			ClientID:   s.ClientID,
+			SeriesID:   &s.ID,
+			StartTime:  start,
+		}
+
+		err = savepoint(ctx, tx, func() error {
+			return insertAppointment(ctx, tx, a)
+		})
+		if err != nil {
+			reason, ok := occurrenceFailure(err)
+			if !ok {
+				return nil, nil, err
+			}
+			skipped = append(skipped, &SkippedOccurrence{StartTime: start, Reason: reason})
+			err = nil
+			continue
+		}
+
+		booked = append(booked, a)
+	}
+
+	if len(booked) == 0 {
+		return nil, skipped, ErrSeriesUnavailable
+	}
+
+	return booked, skipped, nil
+}
+
+func (m AppointmentSeriesModel) Get(id int64) (*AppointmentSeries, error) {
+	query := `
+		SELECT id, provider_id, service_id, staff_id, client_id, frequency, occurrence_count, until_date, start_time, created_at
+		FROM appointment_series
+		WHERE id = $1
+	`
+
+	var s AppointmentSeries
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(
+		&s.ID,
+		&s.ProviderID,
+		&s.ServiceID,
+		&s.StaffID,
+		&s.ClientID,
+		&s.Recurrence.Frequency,
+		&s.Recurrence.Count,
+		&s.Recurrence.Until,
+		&s.StartTime,
+		&s.CreatedAt,
+	)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	loc, err := providerLocation(ctx, m.DB, s.ProviderID)
+	if err != nil {
+		return nil, err
+	}
+
+	s.StartTime = s.StartTime.In(loc)
+
+	return &s, nil
+}
+
+// seriesFollowing returns the confirmed appointments of a series that start at
+// or after start, in order, locking them for the rest of the transaction.
+func seriesFollowing(ctx context.Context, q queryer, seriesID int64, start time.Time) ([]*Appointment, error) {
+	query := `
+		SELECT id, provider_id, service_id, staff_id, client_id, series_id, group_id, start_time, end_time, status, created_at
+		FROM appointments
+		WHERE series_id = $1 AND start_time >= $2 AND status = 'confirmed'
+		ORDER BY start_time
+		FOR UPDATE
+	`
+
+	rows, err := q.QueryContext(ctx, query, seriesID, start)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var appointments []*Appointment
+
+	for rows.Next() {
+		var a Appointment
+		err := rows.Scan(
+			&a.ID,
+			&a.ProviderID,
+			&a.ServiceID,
+			&a.StaffID,
+			&a.ClientID,
+			&a.SeriesID,
+			&a.GroupID,
+			&a.StartTime,
+			&a.EndTime,
+			&a.Status,
+			&a.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		appointments = append(appointments, &a)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return appointments, nil
+}
+
+// CancelFollowing cancels the appointment and every later confirmed
+// occurrence of its series in one transaction. The provider's notice period is
+// checked against the first appointment, unless bypassPolicy is set.
+func (m AppointmentSeriesModel) CancelFollowing(a *Appointment, userID int64, reason *string, bypassPolicy bool) (changes []*AppointmentStatusChange, err error) {
+	if a.SeriesID == nil {
+		return nil, ErrNotInSeries
+	}
+
+	// The following occurrences are only known once they have been read
+	// inside the transaction, so there is time for as many as a series can
+	// have.
+	ctx, cancel := context.WithTimeout(context.Background(), seriesTimeout(maxOccurrences))
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		} else if err != nil {
+			tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	change, err := updateStatus(ctx, tx, a, AppointmentCancelled, userID, reason, bypassPolicy)
+	if err != nil {
+		return nil, err
+	}
+
+	changes = []*AppointmentStatusChange{change}
+
+	following, err := seriesFollowing(ctx, tx, *a.SeriesID, a.StartTime)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, o := range following {
+		change, err := updateStatus(ctx, tx, o, AppointmentCancelled, userID, reason, bypassPolicy)
+		if err != nil {
+			return nil, err
+		}
+		changes = append(changes, change)
+	}
+
+	return changes, nil
+}
+
+// RescheduleFollowing moves the appointment to start and shifts every later
+// confirmed occurrence of its series by the same number of days, to the same
+// local time of day. A failure to move the first appointment aborts the whole
+// change, while later occurrences that cannot be moved are left as they were
+// and returned as skipped.
+func (m AppointmentSeriesModel) RescheduleFollowing(a *Appointment, staffID int64, start time.Time, userID int64, bypassPolicy bool) (reschedules []*AppointmentReschedule, skipped []*SkippedOccurrence, err error) {
+	if a.SeriesID == nil {
+		return nil, nil, ErrNotInSeries
+	}
+
+	// As in CancelFollowing, there is time for a whole series.
+	ctx, cancel := context.WithTimeout(context.Background(), seriesTimeout(maxOccurrences))
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		} else if err != nil {
+			tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	loc, err := providerLocation(ctx, tx, a.ProviderID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	start = start.In(loc)
+	previous := a.StartTime.In(loc)
+	shift := int(DateOf(start).Sub(DateOf(previous).Time).Hours() / 24)
+
+	following, err := seriesFollowing(ctx, tx, *a.SeriesID, previous)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// The appointment is moved along with the rest. If it is no longer
+	// confirmed it is not among them, and rescheduling it reports why.
+	if !slices.ContainsFunc(following, func(o *Appointment) bool { return o.ID == a.ID }) {
+		following = append([]*Appointment{a}, following...)
+	}
+
+	// Moving the series later puts each occurrence onto time the next one
+	// still takes up, so the occurrences are then moved latest first, each
+	// into time already vacated.
+	later := start.After(previous)
+	if later {
+		slices.Reverse(following)
+	}
+
+	skipped = make([]*SkippedOccurrence, 0)
+
+	for _, o := range following {
+		if o.ID == a.ID {
+			rs, err := reschedule(ctx, tx, a, staffID, start, userID, bypassPolicy)
+			if err != nil {
+				return nil, nil, err
+			}
+			reschedules = append(reschedules, rs)
+			continue
+		}
+
+		current := o.StartTime.In(loc)
+		moved := time.Date(current.Year(), current.Month(), current.Day()+shift, start.Hour(), start.Minute(), start.Second(), 0, loc)
+
+		var rs *AppointmentReschedule
+
+		err = savepoint(ctx, tx, func() error {
+			rs, err = reschedule(ctx, tx, o, staffID, moved, userID, bypassPolicy)
+			return err
+		})
+		if err != nil {
+			reason, ok := occurrenceFailure(err)
+			if !ok {
+				return nil, nil, err
+			}
+			skipped = append(skipped, &SkippedOccurrence{AppointmentID: &o.ID, StartTime: moved, Reason: reason})
+			err = nil
+			continue
+		}
+
+		reschedules = append(reschedules, rs)
+	}
+
+	if later {
+		slices.Reverse(reschedules)
+		slices.Reverse(skipped)
+	}
+
+	return reschedules, skipped, nil
+}
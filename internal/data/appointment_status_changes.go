@@ -0,0 +1,82 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+type AppointmentStatusChangeModel struct {
+	DB *sql.DB
+}
+
+type AppointmentStatusChange struct {
+	ID            int64             `json:"id"`
+	AppointmentID int64             `json:"appointment_id"`
+	FromStatus    AppointmentStatus `json:"from_status"`
+	ToStatus      AppointmentStatus `json:"to_status"`
+	ChangedBy     *int64            `json:"changed_by"`
+	Reason        *string           `json:"reason,omitempty"`
+	ChangedAt     time.Time         `json:"changed_at"`
+}
+
+func insertStatusChange(ctx context.Context, q queryer, c *AppointmentStatusChange) error {
+	query := `
+		INSERT INTO appointment_status_changes (appointment_id, from_status, to_status, changed_by, reason)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, changed_at
+	`
+
+	args := []any{
+		c.AppointmentID,
+		c.FromStatus,
+		c.ToStatus,
+		c.ChangedBy,
+		c.Reason,
+	}
+
+	return q.QueryRowContext(ctx, query, args...).Scan(&c.ID, &c.ChangedAt)
+}
+
+func (m AppointmentStatusChangeModel) GetAllForAppointment(appointmentID int64) ([]*AppointmentStatusChange, error) {
+	query := `
+		SELECT id, appointment_id, from_status, to_status, changed_by, reason, changed_at
+		FROM appointment_status_changes
+		WHERE appointment_id = $1
+		ORDER BY changed_at, id
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, appointmentID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	changes := make([]*AppointmentStatusChange, 0)
+
+	for rows.Next() {
+		var c AppointmentStatusChange
+		err := rows.Scan(
+			&c.ID,
+			&c.AppointmentID,
+			&c.FromStatus,
+			&c.ToStatus,
+			&c.ChangedBy,
+			&c.Reason,
+			&c.ChangedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		changes = append(changes, &c)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return changes, nil
+}
@@ -1 +1,721 @@
 package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/tormgibbs/snapluks-backend/internal/validator"
+)
+
+var (
+	ErrStaffServiceMismatch = errors.New("staff does not offer service")
+	ErrSlotUnavailable      = errors.New("slot unavailable")
+	ErrInvalidTransition    = errors.New("invalid status transition")
+	ErrCancellationNotice   = errors.New("inside cancellation notice period")
+	ErrRescheduleLimit      = errors.New("reschedule limit reached")
+	ErrHoldExpired          = errors.New("hold expired")
+)
+
+type AppointmentStatus string
+
+const (
+	AppointmentHeld      AppointmentStatus = "held"
+	AppointmentConfirmed AppointmentStatus = "confirmed"
+	AppointmentCompleted AppointmentStatus = "completed"
+	AppointmentCancelled AppointmentStatus = "cancelled"
+	AppointmentNoShow    AppointmentStatus = "no_show"
+)
+
+var AppointmentStatuses = []string{
+	string(AppointmentHeld),
+	string(AppointmentConfirmed),
+	string(AppointmentCompleted),
+	string(AppointmentCancelled),
+	string(AppointmentNoShow),
+}
+
+// appointmentTransitions lists the statuses each status may move to. Every
+// status other than confirmed is final. Held appointments are confirmed or
+// released through the hold methods rather than by a status change.
+var appointmentTransitions = map[AppointmentStatus][]AppointmentStatus{
+	AppointmentConfirmed: {AppointmentCancelled, AppointmentCompleted, AppointmentNoShow},
+}
+
+func (s AppointmentStatus) CanTransitionTo(next AppointmentStatus) bool {
+	return slices.Contains(appointmentTransitions[s], next)
+}
+
+type AppointmentModel struct {
+	DB *sql.DB
+}
+
+type Appointment struct {
+	ID         int64             `json:"id"`
+	ProviderID int64             `json:"provider_id"`
+	ServiceID  int64             `json:"service_id"`
+	StaffID    int64             `json:"staff_id"`
+	ClientID   int64             `json:"client_id"`
+	SeriesID   *int64            `json:"series_id,omitempty"`
+	GroupID    *int64            `json:"group_id,omitempty"`
+	StartTime  time.Time         `json:"start_time"`
+	EndTime    time.Time         `json:"end_time"`
+	Status     AppointmentStatus `json:"status"`
+	CreatedAt  time.Time         `json:"created_at"`
+
+	// HoldExpiresAt is when a held appointment is released unless it has been
+	// confirmed. It is nil for every other status.
+	HoldExpiresAt *time.Time `json:"hold_expires_at,omitempty"`
+
+	// Blocked is the time the appointment takes on the staff member's
+	// calendar, including the service's buffers.
+	Blocked Interval `json:"-"`
+
+	// SessionKey is shared by the appointments booked into the same session
+	// of a class. It is nil for services that take one client at a time.
+	SessionKey *string `json:"-"`
+}
+
+func ValidateAppointment(v *validator.Validator, a *Appointment) {
+	v.Check(a.ServiceID > 0, "service_id", "must be provided and greater than zero")
+	v.Check(a.StaffID > 0, "staff_id", "must be provided and greater than zero")
+	v.Check(a.ClientID > 0, "client_id", "must be provided and greater than zero")
+
+	v.Check(!a.StartTime.IsZero(), "start_time", "must be provided")
+	v.Check(a.StartTime.After(time.Now()), "start_time", "must be in the future")
+}
+
+// staffServiceProvider returns the provider that both the staff member and the
+// service belong to, or ErrStaffServiceMismatch if the staff member does not
+// offer the service. The composite foreign keys on staff_services guarantee
+// that a matching row can only exist when both share the same provider.
+func staffServiceProvider(ctx context.Context, q queryer, staffID, serviceID int64) (int64, error) {
+	query := `
+		SELECT provider_id
+		FROM staff_services
+		WHERE staff_id = $1 AND service_id = $2
+	`
+
+	var providerID int64
+
+	err := q.QueryRowContext(ctx, query, staffID, serviceID).Scan(&providerID)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return 0, ErrStaffServiceMismatch
+		default:
+			return 0, err
+		}
+	}
+
+	return providerID, nil
+}
+
+// isSlotConflict reports whether err was raised by the exclusion constraint
+// that stops a staff member from being double-booked.
+func isSlotConflict(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23P01" && pgErr.ConstraintName == "appointments_no_overlap"
+}
+
+// checkBookable sets the appointment's end time and blocked time from the
+// service's duration and buffers, moves its times into the provider's time
+// zone and makes sure the whole appointment falls within the provider's
+// opening hours. The blocked time, buffers included, must fall within the
+// staff member's working hours, clear of any time off, of busy times imported
+// from external calendars and of slots held for other waitlisted clients. For
+// a class, it also claims a seat in the session. Overlaps with other
+// appointments are left to the exclusion constraint.
+func checkBookable(ctx context.Context, q queryer, a *Appointment) error {
+	t, err := serviceTiming(ctx, q, a.ProviderID, a.ServiceID)
+	if err != nil {
+		return err
+	}
+
+	loc, err := providerLocation(ctx, q, a.ProviderID)
+	if err != nil {
+		return err
+	}
+
+	a.StartTime = a.StartTime.In(loc)
+	a.EndTime = a.StartTime.Add(t.Duration)
+	a.Blocked = t.block(a.StartTime)
+
+	iv := Interval{Start: a.StartTime, End: a.EndTime}
+	day := a.StartTime
+
+	open, err := openIntervals(ctx, q, a.ProviderID, day)
+	if err != nil {
+		return err
+	}
+
+	if !fitsWithin(open, iv) {
+		return ErrOutsideBusinessHours
+	}
+
+	staffIDs := []int64{a.StaffID}
+
+	working, err := workingIntervals(ctx, q, staffIDs, day, open)
+	if err != nil {
+		return err
+	}
+
+	if !fitsWithin(working[a.StaffID], a.Blocked) {
+		return ErrStaffUnavailable
+	}
+
+	timeOff, err := timeOffIntervals(ctx, q, staffIDs, a.Blocked)
+	if err != nil {
+		return err
+	}
+
+	if len(timeOff[a.StaffID]) > 0 {
+		return ErrStaffUnavailable
+	}
+
+	external, err := externalBusyIntervals(ctx, q, staffIDs, a.Blocked)
+	if err != nil {
+		return err
+	}
+
+	if len(external[a.StaffID]) > 0 {
+		return ErrStaffUnavailable
+	}
+
+	held, err := heldIntervals(ctx, q, staffIDs, a.Blocked, a.ClientID)
+	if err != nil {
+		return err
+	}
+
+	if len(held[a.StaffID]) > 0 {
+		return ErrSlotUnavailable
+	}
+
+	return claimSeat(ctx, q, a)
+}
+
+func (m AppointmentModel) Insert(a *Appointment) (err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		} else if err != nil {
+			tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	a.ProviderID, err = staffServiceProvider(ctx, tx, a.StaffID, a.ServiceID)
+	if err != nil {
+		return err
+	}
+
+	return insertAppointment(ctx, tx, a)
+}
+
+// insertAppointment checks that the appointment can be booked, inserts it and
+// allocates the resources its service needs. The provider of the appointment
+// must already be set. The appointment is confirmed unless its status says
+// otherwise. Expired holds are released first so that they never stand in the
+// way of a booking.
+func insertAppointment(ctx context.Context, q queryer, a *Appointment) error {
+	_, err := releaseExpiredHolds(ctx, q)
+	if err != nil {
+		return err
+	}
+
+	err = checkBookable(ctx, q, a)
+	if err != nil {
+		return err
+	}
+
+	if a.Status == "" {
+		a.Status = AppointmentConfirmed
+	}
+
+	query := `
+		INSERT INTO appointments (provider_id, service_id, staff_id, client_id, series_id, group_id, start_time, end_time, blocked_start, blocked_end, session_key, status, hold_expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+		RETURNING id, status, created_at
+	`
+
+	args := []any{
+		a.ProviderID,
+		a.ServiceID,
+		a.StaffID,
+		a.ClientID,
+		a.SeriesID,
+		a.GroupID,
+		a.StartTime,
+		a.EndTime,
+		a.Blocked.Start,
+		a.Blocked.End,
+		a.SessionKey,
+		a.Status,
+		a.HoldExpiresAt,
+	}
+
+	err = q.QueryRowContext(ctx, query, args...).Scan(&a.ID, &a.Status, &a.CreatedAt)
+	if err != nil {
+		if isSlotConflict(err) {
+			return ErrSlotUnavailable
+		}
+		return fmt.Errorf("inserting appointment: %w", err)
+	}
+
+	return allocateResources(ctx, q, a)
+}
+
+func (m AppointmentModel) Get(id int64) (*Appointment, error) {
+	query := `
+		SELECT id, provider_id, service_id, staff_id, client_id, series_id, group_id, start_time, end_time, status, created_at, hold_expires_at
+		FROM appointments
+		WHERE id = $1
+	`
+
+	var a Appointment
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(
+		&a.ID,
+		&a.ProviderID,
+		&a.ServiceID,
+		&a.StaffID,
+		&a.ClientID,
+		&a.SeriesID,
+		&a.GroupID,
+		&a.StartTime,
+		&a.EndTime,
+		&a.Status,
+		&a.CreatedAt,
+		&a.HoldExpiresAt,
+	)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	err = inProviderZone(ctx, m.DB, &a)
+	if err != nil {
+		return nil, err
+	}
+
+	return &a, nil
+}
+
+// inProviderZone converts the times of each appointment into the time zone of
+// its provider, so that they are returned with the provider's offset.
+func inProviderZone(ctx context.Context, q queryer, appointments ...*Appointment) error {
+	locations := make(map[int64]*time.Location)
+
+	for _, a := range appointments {
+		loc, ok := locations[a.ProviderID]
+		if !ok {
+			var err error
+			loc, err = providerLocation(ctx, q, a.ProviderID)
+			if err != nil {
+				return err
+			}
+			locations[a.ProviderID] = loc
+		}
+
+		a.StartTime = a.StartTime.In(loc)
+		a.EndTime = a.EndTime.In(loc)
+	}
+
+	return nil
+}
+
+// GetAllForSeries returns every appointment of a series in order.
+func (m AppointmentModel) GetAllForSeries(seriesID int64) ([]*Appointment, error) {
+	return m.getAllInOrder("series_id", seriesID)
+}
+
+// GetAllForGroup returns every appointment of a group booking in order.
+func (m AppointmentModel) GetAllForGroup(groupID int64) ([]*Appointment, error) {
+	return m.getAllInOrder("group_id", groupID)
+}
+
+func (m AppointmentModel) getAllInOrder(column string, id int64) ([]*Appointment, error) {
+	query := fmt.Sprintf(`
+		SELECT id, provider_id, service_id, staff_id, client_id, series_id, group_id, start_time, end_time, status, created_at, hold_expires_at
+		FROM appointments
+		WHERE %s = $1
+		ORDER BY start_time
+	`, column)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	appointments := make([]*Appointment, 0)
+
+	for rows.Next() {
+		var a Appointment
+		err := rows.Scan(
+			&a.ID,
+			&a.ProviderID,
+			&a.ServiceID,
+			&a.StaffID,
+			&a.ClientID,
+			&a.SeriesID,
+			&a.GroupID,
+			&a.StartTime,
+			&a.EndTime,
+			&a.Status,
+			&a.CreatedAt,
+			&a.HoldExpiresAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		appointments = append(appointments, &a)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	err = inProviderZone(ctx, m.DB, appointments...)
+	if err != nil {
+		return nil, err
+	}
+
+	return appointments, nil
+}
+
+func (m AppointmentModel) GetAllForClient(clientID int64, status string, filters Filters) ([]*Appointment, Metadata, error) {
+	return m.getAll("client_id", clientID, status, filters)
+}
+
+func (m AppointmentModel) GetAllForProvider(providerID int64, status string, filters Filters) ([]*Appointment, Metadata, error) {
+	return m.getAll("provider_id", providerID, status, filters)
+}
+
+// getAll lists the appointments whose owner column matches id. The column is
+// never taken from user input, so it is safe to interpolate.
+func (m AppointmentModel) getAll(column string, id int64, status string, filters Filters) ([]*Appointment, Metadata, error) {
+	query := fmt.Sprintf(`
+		SELECT count(*) OVER(), id, provider_id, service_id, staff_id, client_id, series_id, group_id, start_time, end_time, status, created_at, hold_expires_at
+		FROM appointments
+		WHERE %s = $1
+		AND (status::text = $2 OR $2 = '')
+		ORDER BY %s %s, id ASC
+		LIMIT $3 OFFSET $4
+	`, column, filters.sortColumn(), filters.sortDirection())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	args := []any{id, status, filters.limit(), filters.offset()}
+
+	rows, err := m.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	appointments := make([]*Appointment, 0)
+
+	for rows.Next() {
+		var a Appointment
+		err := rows.Scan(
+			&totalRecords,
+			&a.ID,
+			&a.ProviderID,
+			&a.ServiceID,
+			&a.StaffID,
+			&a.ClientID,
+			&a.SeriesID,
+			&a.GroupID,
+			&a.StartTime,
+			&a.EndTime,
+			&a.Status,
+			&a.CreatedAt,
+			&a.HoldExpiresAt,
+		)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+		appointments = append(appointments, &a)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	err = inProviderZone(ctx, m.DB, appointments...)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := CalculateMetadata(totalRecords, filters.Page, filters.PageSize)
+
+	return appointments, metadata, nil
+}
+
+// UpdateStatus moves the appointment to the given status and records who made
+// the change. The row is locked first so that concurrent transitions are
+// checked against the latest status rather than the caller's copy.
+// Cancellations must respect the provider's notice period unless bypassPolicy
+// is set.
+func (m AppointmentModel) UpdateStatus(a *Appointment, to AppointmentStatus, userID int64, reason *string, bypassPolicy bool) (change *AppointmentStatusChange, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		} else if err != nil {
+			tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	return updateStatus(ctx, tx, a, to, userID, reason, bypassPolicy)
+}
+
+// updateStatus locks the appointment, checks the transition and the provider's
+// notice period, and records the change.
+func updateStatus(ctx context.Context, q queryer, a *Appointment, to AppointmentStatus, userID int64, reason *string, bypassPolicy bool) (*AppointmentStatusChange, error) {
+	query := `
+		SELECT a.status, a.start_time, p.cancellation_notice_hours
+		FROM appointments a
+		INNER JOIN providers p ON p.id = a.provider_id
+		WHERE a.id = $1
+		FOR UPDATE OF a
+	`
+
+	var (
+		from   AppointmentStatus
+		start  time.Time
+		policy CancellationPolicy
+	)
+
+	err := q.QueryRowContext(ctx, query, a.ID).Scan(&from, &start, &policy.NoticeHours)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	if !from.CanTransitionTo(to) {
+		a.Status = from
+		return nil, ErrInvalidTransition
+	}
+
+	if to == AppointmentCancelled && !bypassPolicy && policy.withinNotice(start, time.Now()) {
+		return nil, ErrCancellationNotice
+	}
+
+	_, err = q.ExecContext(ctx, `UPDATE appointments SET status = $1 WHERE id = $2`, to, a.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	change := &AppointmentStatusChange{
+		AppointmentID: a.ID,
+		FromStatus:    from,
+		ToStatus:      to,
+		ChangedBy:     &userID,
+		Reason:        reason,
+	}
+
+	err = insertStatusChange(ctx, q, change)
+	if err != nil {
+		return nil, err
+	}
+
+	a.Status = to
+
+	return change, nil
+}
+
+// Reschedule moves a confirmed appointment to a new start time and, if staffID
+// is non-zero, to another staff member of the same provider. The staff-service,
+// business-hours and overlap checks are repeated inside the same transaction
+// that records the previous times. The provider's notice period and reschedule
+// limit apply unless bypassPolicy is set.
+func (m AppointmentModel) Reschedule(a *Appointment, staffID int64, start time.Time, userID int64, bypassPolicy bool) (rs *AppointmentReschedule, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		} else if err != nil {
+			tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	return reschedule(ctx, tx, a, staffID, start, userID, bypassPolicy)
+}
+
+// reschedule locks the appointment, applies the provider's policy and moves
+// the appointment, recording its previous staff member and times. As with a
+// booking, expired holds are released first so that they never stand in the
+// way of the move.
+func reschedule(ctx context.Context, q queryer, a *Appointment, staffID int64, start time.Time, userID int64, bypassPolicy bool) (*AppointmentReschedule, error) {
+	query := `
+		SELECT a.status, a.staff_id, a.start_time, a.end_time,
+			p.cancellation_notice_hours, p.max_reschedules,
+			(SELECT count(*) FROM appointment_reschedules r WHERE r.appointment_id = a.id)
+		FROM appointments a
+		INNER JOIN providers p ON p.id = a.provider_id
+		WHERE a.id = $1
+		FOR UPDATE OF a
+	`
+
+	rs := &AppointmentReschedule{
+		AppointmentID: a.ID,
+		RescheduledBy: &userID,
+	}
+
+	var (
+		status      AppointmentStatus
+		policy      CancellationPolicy
+		reschedules int
+	)
+
+	err := q.QueryRowContext(ctx, query, a.ID).Scan(
+		&status,
+		&rs.PreviousStaffID,
+		&rs.PreviousStartTime,
+		&rs.PreviousEndTime,
+		&policy.NoticeHours,
+		&policy.MaxReschedules,
+		&reschedules,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	if status != AppointmentConfirmed {
+		a.Status = status
+		return nil, ErrInvalidTransition
+	}
+
+	if !bypassPolicy {
+		if policy.withinNotice(rs.PreviousStartTime, time.Now()) {
+			return nil, ErrCancellationNotice
+		}
+
+		if policy.rescheduleLimitReached(reschedules) {
+			return nil, ErrRescheduleLimit
+		}
+	}
+
+	updated := *a
+	updated.StartTime = start
+	updated.StaffID = rs.PreviousStaffID
+
+	if staffID != 0 {
+		updated.StaffID = staffID
+	}
+
+	providerID, err := staffServiceProvider(ctx, q, updated.StaffID, updated.ServiceID)
+	if err != nil {
+		return nil, err
+	}
+
+	if providerID != a.ProviderID {
+		return nil, ErrStaffServiceMismatch
+	}
+
+	_, err = releaseExpiredHolds(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+
+	err = checkBookable(ctx, q, &updated)
+	if err != nil {
+		return nil, err
+	}
+
+	query = `
+		UPDATE appointments
+		SET staff_id = $1, start_time = $2, end_time = $3, blocked_start = $4, blocked_end = $5, session_key = $6
+		WHERE id = $7
+	`
+
+	args := []any{
+		updated.StaffID,
+		updated.StartTime,
+		updated.EndTime,
+		updated.Blocked.Start,
+		updated.Blocked.End,
+		updated.SessionKey,
+		a.ID,
+	}
+
+	_, err = q.ExecContext(ctx, query, args...)
+	if err != nil {
+		if isSlotConflict(err) {
+			return nil, ErrSlotUnavailable
+		}
+		return nil, err
+	}
+
+	err = allocateResources(ctx, q, &updated)
+	if err != nil {
+		return nil, err
+	}
+
+	err = insertReschedule(ctx, q, rs)
+	if err != nil {
+		return nil, err
+	}
+
+	*a = updated
+
+	return rs, nil
+}
@@ -0,0 +1,218 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+)
+
+const (
+	ThrottleAccount = "account"
+	ThrottleIP      = "ip"
+)
+
+const (
+	// throttleBaseDelay is how long an account or address is locked out for
+	// after its first failure past the free attempts. Every further failure
+	// doubles it, up to maxLockout.
+	throttleBaseDelay = 2 * time.Second
+	maxLockout        = 15 * time.Minute
+
+	// throttleResetAfter is how long it takes for failures to be forgotten
+	// when no more are made.
+	throttleResetAfter = time.Hour
+)
+
+// freeAttempts is how many failures are allowed of each kind of key before
+// lockouts start. Addresses get more, since users behind a shared address
+// fail on behalf of one another.
+var freeAttempts = map[string]int{
+	ThrottleAccount: 5,
+	ThrottleIP:      20,
+}
+
+// ThrottleKey names what failed attempts are counted against: an account,
+// by email address, or the IP address the attempts came from.
+type ThrottleKey struct {
+	Kind  string
+	Value string
+}
+
+func AccountThrottleKey(email string) ThrottleKey {
+	return ThrottleKey{Kind: ThrottleAccount, Value: strings.ToLower(email)}
+}
+
+func IPThrottleKey(ip string) ThrottleKey {
+	return ThrottleKey{Kind: ThrottleIP, Value: ip}
+}
+
+func throttleKeyArrays(keys []ThrottleKey) ([]string, []string) {
+	kinds := make([]string, len(keys))
+	values := make([]string, len(keys))
+
+	for i, k := range keys {
+		kinds[i], values[i] = k.Kind, k.Value
+	}
+
+	return kinds, values
+}
+
+// lockout returns how long to lock out after the nth failure past the free
+// attempts.
+func lockout(n int) time.Duration {
+	if n > 20 {
+		return maxLockout
+	}
+	return min(throttleBaseDelay<<(n-1), maxLockout)
+}
+
+type AuthThrottleModel struct {
+	DB *sql.DB
+}
+
+// Attempt reserves an attempt at the action against each key before it is
+// made, counting it as a failure up front. It returns how long the action
+// stays locked out for if any of the keys is, in which case nothing is
+// counted, and zero otherwise. The count and the lockout are worked out in
+// one statement per key, so concurrent attempts are counted one after the
+// other and none of them can slip in before an earlier one locks the key.
+func (m AuthThrottleModel) Attempt(action string, keys ...ThrottleKey) (retryAfter time.Duration, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	// A locked out attempt is rolled back, so that it is not counted
+	// against the keys that are not locked.
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		} else if err != nil || retryAfter > 0 {
+			tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	query := `
+		INSERT INTO auth_throttles (action, kind, value, failures)
+		VALUES ($1, $2, $3, 1)
+		ON CONFLICT (action, kind, value) DO UPDATE
+		SET failures = CASE
+				WHEN auth_throttles.last_failure_at < NOW() - make_interval(secs => $4) THEN 1
+				ELSE auth_throttles.failures + 1
+			END,
+			last_failure_at = NOW()
+		RETURNING failures, COALESCE(CEIL(EXTRACT(EPOCH FROM locked_until - NOW())), 0)::bigint
+	`
+
+	for _, k := range keys {
+		var (
+			failures int
+			seconds  int64
+		)
+
+		err = tx.QueryRowContext(ctx, query, action, k.Kind, k.Value, throttleResetAfter.Seconds()).Scan(&failures, &seconds)
+		if err != nil {
+			return 0, err
+		}
+
+		if seconds > 0 {
+			retryAfter = max(retryAfter, time.Duration(seconds)*time.Second)
+			continue
+		}
+
+		if failures <= freeAttempts[k.Kind] {
+			continue
+		}
+
+		// The attempt goes ahead, but the key is locked out straight away
+		// for the ones after it.
+		delay := lockout(failures - freeAttempts[k.Kind])
+
+		_, err = tx.ExecContext(ctx, `
+			UPDATE auth_throttles
+			SET locked_until = NOW() + make_interval(secs => $4)
+			WHERE action = $1 AND kind = $2 AND value = $3
+		`, action, k.Kind, k.Value, delay.Seconds())
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return retryAfter, nil
+}
+
+// Succeed records that an attempt reserved with Attempt succeeded. The
+// failures counted against an account are forgotten, while an address only
+// has the attempt taken off its count, since the other attempts made from it
+// may have been at other accounts.
+func (m AuthThrottleModel) Succeed(action string, keys ...ThrottleKey) error {
+	query := `
+		UPDATE auth_throttles
+		SET failures = GREATEST(failures - 1, 0)
+		WHERE action = $1 AND kind = $2 AND value = $3
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	for _, k := range keys {
+		if k.Kind == ThrottleAccount {
+			err := m.Reset(action, k)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		_, err := m.DB.ExecContext(ctx, query, action, k.Kind, k.Value)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Reset forgets the failed attempts at the action made against the keys.
+func (m AuthThrottleModel) Reset(action string, keys ...ThrottleKey) error {
+	query := `
+		DELETE FROM auth_throttles
+		WHERE action = $1
+		AND (kind, value) IN (SELECT * FROM unnest($2::text[], $3::text[]))
+	`
+
+	kinds, values := throttleKeyArrays(keys)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, action, kinds, values)
+	return err
+}
+
+// DeleteStale removes the failures that have been forgotten and are no longer
+// locking anything out, and returns how many were removed.
+func (m AuthThrottleModel) DeleteStale() (int64, error) {
+	query := `
+		DELETE FROM auth_throttles
+		WHERE last_failure_at < NOW() - make_interval(secs => $1)
+		AND (locked_until IS NULL OR locked_until <= NOW())
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, throttleResetAfter.Seconds())
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
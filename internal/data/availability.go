@@ -0,0 +1,838 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"slices"
+	"time"
+)
+
+var (
+	ErrOutsideBusinessHours = errors.New("outside business hours")
+	ErrStaffUnavailable     = errors.New("staff unavailable")
+)
+
+// slotStep is the granularity at which bookable start times are offered.
+const slotStep = 15 * time.Minute
+
+// queryer is satisfied by both *sql.DB and *sql.Tx so that the availability
+// helpers can be shared between read-only lookups and booking transactions.
+type queryer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+type Interval struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+func (i Interval) Overlaps(o Interval) bool {
+	return i.Start.Before(o.End) && o.Start.Before(i.End)
+}
+
+func (i Interval) Contains(o Interval) bool {
+	return !o.Start.Before(i.Start) && !o.End.After(i.End)
+}
+
+// Slot is a bookable start time. For a class, Seats is the number of places
+// left at that time across the staff members listed.
+type Slot struct {
+	ServiceID int64     `json:"service_id,omitempty"`
+	Start     time.Time `json:"start"`
+	End       time.Time `json:"end"`
+	StaffIDs  []int64   `json:"staff_ids"`
+	Seats     int       `json:"seats,omitempty"`
+}
+
+type AvailabilityModel struct {
+	DB *sql.DB
+}
+
+// freeSlots walks every open interval in steps and returns the start times at
+// which the service, buffers included, fits without touching a busy interval.
+func freeSlots(open, busy []Interval, t timing, step time.Duration) []time.Time {
+	var starts []time.Time
+
+	for _, window := range open {
+		for start := window.Start; !start.Add(t.Duration + t.After).After(window.End); start = start.Add(step) {
+			candidate := t.block(start)
+			if candidate.Start.Before(window.Start) {
+				continue
+			}
+
+			free := true
+			for _, b := range busy {
+				if candidate.Overlaps(b) {
+					free = false
+					break
+				}
+			}
+
+			if free {
+				starts = append(starts, start)
+			}
+		}
+	}
+
+	return starts
+}
+
+// earliestFit returns the earliest time from the given moment at which a block
+// of the given duration fits within the working intervals without touching a
+// busy interval. Such a time is either the moment itself, the start of a
+// working interval or the end of a busy one, so only those are tried.
+func earliestFit(working, busy []Interval, from time.Time, duration time.Duration) (time.Time, bool) {
+	candidates := []time.Time{from}
+
+	for _, w := range working {
+		if w.Start.After(from) {
+			candidates = append(candidates, w.Start)
+		}
+	}
+
+	for _, b := range busy {
+		if b.End.After(from) {
+			candidates = append(candidates, b.End)
+		}
+	}
+
+	slices.SortFunc(candidates, time.Time.Compare)
+
+next:
+	for _, start := range candidates {
+		candidate := Interval{Start: start, End: start.Add(duration)}
+
+		if !fitsWithin(working, candidate) {
+			continue
+		}
+
+		for _, b := range busy {
+			if candidate.Overlaps(b) {
+				continue next
+			}
+		}
+
+		return start, true
+	}
+
+	return time.Time{}, false
+}
+
+func fitsWithin(open []Interval, iv Interval) bool {
+	for _, window := range open {
+		if window.Contains(iv) {
+			return true
+		}
+	}
+	return false
+}
+
+// timing is how long a service takes and the buffers kept clear on the staff
+// member's calendar before and after it.
+type timing struct {
+	Duration time.Duration
+	Before   time.Duration
+	After    time.Duration
+}
+
+// block returns the time the service starting at start takes on the staff
+// member's calendar, buffers included.
+func (t timing) block(start time.Time) Interval {
+	return Interval{Start: start.Add(-t.Before), End: start.Add(t.Duration + t.After)}
+}
+
+// serviceTiming looks up the duration and buffers of a service offered by the
+// provider.
+func serviceTiming(ctx context.Context, q queryer, providerID, serviceID int64) (timing, error) {
+	query := `
+		SELECT EXTRACT(EPOCH FROM duration)::bigint,
+			EXTRACT(EPOCH FROM buffer_before)::bigint,
+			EXTRACT(EPOCH FROM buffer_after)::bigint
+		FROM services
+		WHERE id = $1 AND provider_id = $2
+	`
+
+	var duration, before, after int64
+
+	err := q.QueryRowContext(ctx, query, serviceID, providerID).Scan(&duration, &before, &after)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return timing{}, ErrServiceNotFound
+		default:
+			return timing{}, err
+		}
+	}
+
+	return timing{
+		Duration: time.Duration(duration) * time.Second,
+		Before:   time.Duration(before) * time.Second,
+		After:    time.Duration(after) * time.Second,
+	}, nil
+}
+
+// serviceDuration looks up the duration of a service offered by the provider.
+func serviceDuration(ctx context.Context, q queryer, providerID, serviceID int64) (time.Duration, error) {
+	query := `
+		SELECT EXTRACT(EPOCH FROM duration)::bigint
+		FROM services
+		WHERE id = $1 AND provider_id = $2
+	`
+
+	var seconds int64
+
+	err := q.QueryRowContext(ctx, query, serviceID, providerID).Scan(&seconds)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return 0, ErrServiceNotFound
+		default:
+			return 0, err
+		}
+	}
+
+	return time.Duration(seconds) * time.Second, nil
+}
+
+// serviceStaff returns the IDs of every staff member linked to the service.
+func serviceStaff(ctx context.Context, q queryer, providerID, serviceID int64) ([]int64, error) {
+	query := `
+		SELECT staff_id
+		FROM staff_services
+		WHERE service_id = $1 AND provider_id = $2
+		ORDER BY staff_id
+	`
+
+	rows, err := q.QueryContext(ctx, query, serviceID, providerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var staffIDs []int64
+
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		staffIDs = append(staffIDs, id)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return staffIDs, nil
+}
+
+type timeRange struct {
+	Start LocalTime
+	End   LocalTime
+}
+
+func (tr timeRange) on(day time.Time) Interval {
+	return Interval{Start: tr.Start.On(day), End: tr.End.On(day)}
+}
+
+// weeklyHours returns the provider's regular opening hours for a day of the
+// week. A closed day yields no ranges.
+func weeklyHours(ctx context.Context, q queryer, providerID int64, dow time.Weekday) ([]timeRange, error) {
+	query := `
+		SELECT open_time, close_time
+		FROM provider_business_hours
+		WHERE provider_id = $1 AND day_of_week = $2 AND NOT is_closed
+		ORDER BY open_time
+	`
+
+	rows, err := q.QueryContext(ctx, query, providerID, int(dow))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ranges []timeRange
+
+	for rows.Next() {
+		var tr timeRange
+
+		err := rows.Scan(&tr.Start, &tr.End)
+		if err != nil {
+			return nil, err
+		}
+
+		ranges = append(ranges, tr)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return ranges, nil
+}
+
+// dayHours returns the provider's opening hours on the given day. An hour
+// exception covering the date takes precedence over the weekly hours.
+func dayHours(ctx context.Context, q queryer, providerID int64, day time.Time) ([]timeRange, error) {
+	exception, err := exceptionHours(ctx, q, providerID, DateOf(day))
+	if err != nil {
+		return nil, err
+	}
+
+	if exception == nil {
+		return weeklyHours(ctx, q, providerID, day.Weekday())
+	}
+
+	if exception.IsClosed {
+		return nil, nil
+	}
+
+	return []timeRange{{Start: *exception.OpenTime, End: *exception.CloseTime}}, nil
+}
+
+// openIntervals returns the provider's opening hours on the given day.
+func openIntervals(ctx context.Context, q queryer, providerID int64, day time.Time) ([]Interval, error) {
+	ranges, err := dayHours(ctx, q, providerID, day)
+	if err != nil {
+		return nil, err
+	}
+
+	open := make([]Interval, 0, len(ranges))
+	for _, tr := range ranges {
+		open = append(open, tr.on(day))
+	}
+
+	return open, nil
+}
+
+// staffShifts returns the weekly schedule of each staff member for a day of
+// the week. Staff members without any schedule are left out of the map, while
+// those who have a schedule but are off that day map to an empty slice.
+func staffShifts(ctx context.Context, q queryer, staffIDs []int64, dow time.Weekday) (map[int64][]timeRange, error) {
+	query := `
+		SELECT s.id, sch.start_time, sch.end_time
+		FROM staff s
+		LEFT JOIN staff_schedules sch ON sch.staff_id = s.id AND sch.day_of_week = $2
+		WHERE s.id = ANY($1)
+		AND EXISTS (SELECT 1 FROM staff_schedules x WHERE x.staff_id = s.id)
+		ORDER BY s.id, sch.start_time
+	`
+
+	rows, err := q.QueryContext(ctx, query, staffIDs, int(dow))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	shifts := make(map[int64][]timeRange)
+
+	for rows.Next() {
+		var (
+			staffID int64
+			start   *LocalTime
+			end     *LocalTime
+		)
+
+		err := rows.Scan(&staffID, &start, &end)
+		if err != nil {
+			return nil, err
+		}
+
+		if _, ok := shifts[staffID]; !ok {
+			shifts[staffID] = []timeRange{}
+		}
+
+		if start != nil && end != nil {
+			shifts[staffID] = append(shifts[staffID], timeRange{Start: *start, End: *end})
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return shifts, nil
+}
+
+// workingIntervals narrows the provider's open intervals on the day down to
+// the hours each staff member is scheduled to work.
+func workingIntervals(ctx context.Context, q queryer, staffIDs []int64, day time.Time, open []Interval) (map[int64][]Interval, error) {
+	shifts, err := staffShifts(ctx, q, staffIDs, day.Weekday())
+	if err != nil {
+		return nil, err
+	}
+
+	working := make(map[int64][]Interval, len(staffIDs))
+
+	for _, id := range staffIDs {
+		ranges, ok := shifts[id]
+		if !ok {
+			working[id] = open
+			continue
+		}
+
+		var scheduled []Interval
+		for _, tr := range ranges {
+			scheduled = append(scheduled, tr.on(day))
+		}
+
+		working[id] = intersect(open, scheduled)
+	}
+
+	return working, nil
+}
+
+// bookedIntervals returns, per staff member, the time already taken by
+// appointments that overlap the window, buffers included.
+func bookedIntervals(ctx context.Context, q queryer, staffIDs []int64, window Interval) (map[int64][]Interval, error) {
+	query := `
+		SELECT staff_id, blocked_start, blocked_end
+		FROM appointments
+		WHERE staff_id = ANY($1)
+		AND status <> 'cancelled'
+		AND blocked_start < $3
+		AND blocked_end > $2
+	`
+
+	return staffIntervals(ctx, q, query, staffIDs, window)
+}
+
+// timeOffIntervals returns, per staff member, the time off and breaks that
+// overlap the window.
+func timeOffIntervals(ctx context.Context, q queryer, staffIDs []int64, window Interval) (map[int64][]Interval, error) {
+	query := `
+		SELECT staff_id, start_time, end_time
+		FROM staff_time_off
+		WHERE staff_id = ANY($1)
+		AND start_time < $3
+		AND end_time > $2
+	`
+
+	return staffIntervals(ctx, q, query, staffIDs, window)
+}
+
+// heldIntervals returns, per staff member, the slots that overlap the window
+// and are being held for a waitlisted client until their claim token expires.
+// Holds for exceptClientID are left out, so the client a slot is held for can
+// still book it.
+func heldIntervals(ctx context.Context, q queryer, staffIDs []int64, window Interval, exceptClientID int64) (map[int64][]Interval, error) {
+	query := `
+		SELECT o.staff_id, o.start_time, o.end_time
+		FROM waitlist_offers o
+		INNER JOIN waitlist_entries e ON e.id = o.entry_id
+		WHERE o.staff_id = ANY($1)
+		AND o.claimed_at IS NULL
+		AND o.expiry > NOW()
+		AND o.start_time < $3
+		AND o.end_time > $2
+		AND e.client_id <> $4
+	`
+
+	return staffIntervals(ctx, q, query, staffIDs, window, exceptClientID)
+}
+
+// staffIntervals runs a query selecting (id, start, end) rows for the staff
+// members, or other IDs such as resources, and window and groups the intervals
+// by ID. Any extra arguments are passed to the query after the window.
+func staffIntervals(ctx context.Context, q queryer, query string, staffIDs []int64, window Interval, args ...any) (map[int64][]Interval, error) {
+	args = append([]any{staffIDs, window.Start, window.End}, args...)
+
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	intervals := make(map[int64][]Interval)
+
+	for rows.Next() {
+		var (
+			staffID int64
+			iv      Interval
+		)
+
+		err := rows.Scan(&staffID, &iv.Start, &iv.End)
+		if err != nil {
+			return nil, err
+		}
+
+		intervals[staffID] = append(intervals[staffID], iv)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return intervals, nil
+}
+
+// intersect returns the parts of a that are also covered by b.
+func intersect(a, b []Interval) []Interval {
+	var result []Interval
+
+	for _, x := range a {
+		for _, y := range b {
+			iv := Interval{Start: maxTime(x.Start, y.Start), End: minTime(x.End, y.End)}
+			if iv.Start.Before(iv.End) {
+				result = append(result, iv)
+			}
+		}
+	}
+
+	return result
+}
+
+// span returns the smallest interval covering all of the given intervals.
+func span(intervals []Interval) Interval {
+	var window Interval
+
+	for i, iv := range intervals {
+		if i == 0 || iv.Start.Before(window.Start) {
+			window.Start = iv.Start
+		}
+		if i == 0 || iv.End.After(window.End) {
+			window.End = iv.End
+		}
+	}
+
+	return window
+}
+
+// staffDay holds what the availability engine knows about a set of staff
+// members on one day: the hours each of them works and the time each of them
+// is already booked, off or busy elsewhere.
+type staffDay struct {
+	working map[int64][]Interval
+	busy    map[int64][]Interval
+}
+
+func loadStaffDay(ctx context.Context, q queryer, providerID int64, staffIDs []int64, day time.Time) (*staffDay, error) {
+	d := &staffDay{
+		working: make(map[int64][]Interval),
+		busy:    make(map[int64][]Interval),
+	}
+
+	open, err := openIntervals(ctx, q, providerID, day)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(open) == 0 || len(staffIDs) == 0 {
+		return d, nil
+	}
+
+	d.working, err = workingIntervals(ctx, q, staffIDs, day, open)
+	if err != nil {
+		return nil, err
+	}
+
+	window := span(open)
+
+	d.busy, err = bookedIntervals(ctx, q, staffIDs, window)
+	if err != nil {
+		return nil, err
+	}
+
+	timeOff, err := timeOffIntervals(ctx, q, staffIDs, window)
+	if err != nil {
+		return nil, err
+	}
+
+	external, err := externalBusyIntervals(ctx, q, staffIDs, window)
+	if err != nil {
+		return nil, err
+	}
+
+	held, err := heldIntervals(ctx, q, staffIDs, window, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, extra := range []map[int64][]Interval{timeOff, external, held} {
+		for id, intervals := range extra {
+			d.busy[id] = append(d.busy[id], intervals...)
+		}
+	}
+
+	return d, nil
+}
+
+// isFree reports whether the staff member works throughout iv and has nothing
+// else booked during it.
+func (d *staffDay) isFree(staffID int64, iv Interval) bool {
+	if !fitsWithin(d.working[staffID], iv) {
+		return false
+	}
+
+	for _, b := range d.busy[staffID] {
+		if iv.Overlaps(b) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// localDay returns midnight of day's calendar date in the provider's time zone.
+func localDay(ctx context.Context, q queryer, providerID int64, day time.Time) (time.Time, error) {
+	loc, err := providerLocation(ctx, q, providerID)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, loc), nil
+}
+
+// offeringStaff returns the staff members offering the service, narrowed down
+// to staffID if it is non-zero.
+func offeringStaff(ctx context.Context, q queryer, providerID, serviceID, staffID int64) ([]int64, error) {
+	staffIDs, err := serviceStaff(ctx, q, providerID, serviceID)
+	if err != nil {
+		return nil, err
+	}
+
+	if staffID != 0 {
+		if !slices.Contains(staffIDs, staffID) {
+			return nil, ErrStaffServiceMismatch
+		}
+		staffIDs = []int64{staffID}
+	}
+
+	return staffIDs, nil
+}
+
+// GetForService returns the bookable slots for a service on the given day. If
+// staffID is zero, the slots of every staff member offering the service are
+// merged and each slot lists the staff members free at that time. For a
+// class, sessions that already have clients are offered until they are full.
+//
+// Only the calendar date of day is used. It is read in the provider's time
+// zone, and the slots are returned in that zone, so a day on which clocks
+// change is simply shorter or longer than usual.
+func (m AvailabilityModel) GetForService(providerID, serviceID, staffID int64, day time.Time) ([]*Slot, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	day, err := localDay(ctx, m.DB, providerID, day)
+	if err != nil {
+		return nil, err
+	}
+
+	t, err := serviceTiming(ctx, m.DB, providerID, serviceID)
+	if err != nil {
+		return nil, err
+	}
+
+	staffIDs, err := offeringStaff(ctx, m.DB, providerID, serviceID, staffID)
+	if err != nil {
+		return nil, err
+	}
+
+	d, err := loadStaffDay(ctx, m.DB, providerID, staffIDs, day)
+	if err != nil {
+		return nil, err
+	}
+
+	resources, err := loadResourceDay(ctx, m.DB, []int64{serviceID}, day)
+	if err != nil {
+		return nil, err
+	}
+
+	capacity, err := serviceCapacity(ctx, m.DB, serviceID)
+	if err != nil {
+		return nil, err
+	}
+
+	var sessions map[int64][]session
+	if capacity > 1 {
+		sessions, err = sessionBookings(ctx, m.DB, serviceID, staffIDs, Interval{Start: day, End: day.AddDate(0, 0, 1)})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	slots := make([]*Slot, 0)
+	now := time.Now()
+	byStart := make(map[time.Time]*Slot)
+
+	add := func(staffID int64, start time.Time, seats int) {
+		slot, ok := byStart[start]
+		if !ok {
+			slot = &Slot{Start: start, End: start.Add(t.Duration)}
+			byStart[start] = slot
+			slots = append(slots, slot)
+		}
+		slot.StaffIDs = append(slot.StaffIDs, staffID)
+
+		if capacity > 1 {
+			slot.Seats += seats
+		}
+	}
+
+	for _, id := range staffIDs {
+		for _, start := range freeSlots(d.working[id], d.busy[id], t, slotStep) {
+			if start.Before(now) || !resources.isFree(serviceID, t.block(start)) {
+				continue
+			}
+			add(id, start, capacity)
+		}
+
+		// A session that has started taking bookings already holds the staff
+		// member's time and its resources, so it is offered as long as it
+		// has seats left.
+		for _, s := range sessions[id] {
+			if s.Start.Before(now) || s.Booked >= capacity {
+				continue
+			}
+			add(id, s.Start.In(day.Location()), capacity-s.Booked)
+		}
+	}
+
+	slices.SortFunc(slots, func(a, b *Slot) int {
+		return a.Start.Compare(b.Start)
+	})
+
+	return slots, nil
+}
+
+// SequenceSlot is a start time at which a sequence of services can be booked
+// back to back. Each part lists the staff members free for that service.
+type SequenceSlot struct {
+	Start    time.Time `json:"start"`
+	End      time.Time `json:"end"`
+	Services []*Slot   `json:"services"`
+}
+
+// GetForServices returns the start times on the given day at which all of the
+// services can be booked one after the other without a gap. Different staff
+// members may perform different services. If staffID is non-zero, that staff
+// member must perform all of them, and each service then follows the previous
+// one once both of their buffers have passed.
+func (m AvailabilityModel) GetForServices(providerID int64, serviceIDs []int64, staffID int64, day time.Time) ([]*SequenceSlot, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	day, err := localDay(ctx, m.DB, providerID, day)
+	if err != nil {
+		return nil, err
+	}
+
+	timings := make([]timing, len(serviceIDs))
+	staffFor := make([][]int64, len(serviceIDs))
+	var allStaff []int64
+
+	for i, serviceID := range serviceIDs {
+		timings[i], err = serviceTiming(ctx, m.DB, providerID, serviceID)
+		if err != nil {
+			return nil, err
+		}
+
+		staffFor[i], err = offeringStaff(ctx, m.DB, providerID, serviceID, staffID)
+		if err != nil {
+			return nil, err
+		}
+
+		allStaff = append(allStaff, staffFor[i]...)
+	}
+
+	allStaff = slices.Compact(slices.Sorted(slices.Values(allStaff)))
+
+	d, err := loadStaffDay(ctx, m.DB, providerID, allStaff, day)
+	if err != nil {
+		return nil, err
+	}
+
+	resources, err := loadResourceDay(ctx, m.DB, serviceIDs, day)
+	if err != nil {
+		return nil, err
+	}
+
+	// Candidate start times are those at which someone can begin the first
+	// service. Each candidate is then walked through the rest of the sequence.
+	var starts []time.Time
+	for _, id := range staffFor[0] {
+		starts = append(starts, freeSlots(d.working[id], d.busy[id], timings[0], slotStep)...)
+	}
+
+	slices.SortFunc(starts, func(a, b time.Time) int {
+		return a.Compare(b)
+	})
+	starts = slices.CompactFunc(starts, func(a, b time.Time) bool {
+		return a.Equal(b)
+	})
+
+	slots := make([]*SequenceSlot, 0)
+	now := time.Now()
+
+	for _, start := range starts {
+		if start.Before(now) {
+			continue
+		}
+
+		slot := &SequenceSlot{Start: start}
+		cursor := start
+
+		for i, serviceID := range serviceIDs {
+			if i > 0 && staffID != 0 {
+				cursor = cursor.Add(timings[i-1].After + timings[i].Before)
+			}
+
+			part := &Slot{ServiceID: serviceID, Start: cursor, End: cursor.Add(timings[i].Duration)}
+			block := timings[i].block(cursor)
+
+			if !resources.isFree(serviceID, block) {
+				slot = nil
+				break
+			}
+
+			for _, id := range staffFor[i] {
+				if !d.isFree(id, block) {
+					continue
+				}
+
+				// The only staff member able to perform the previous service
+				// is busy with it until its buffer has passed.
+				if i > 0 && staffID == 0 {
+					prev := slot.Services[i-1]
+					if slices.Equal(prev.StaffIDs, []int64{id}) && block.Overlaps(timings[i-1].block(prev.Start)) {
+						continue
+					}
+				}
+
+				part.StaffIDs = append(part.StaffIDs, id)
+			}
+
+			if len(part.StaffIDs) == 0 {
+				slot = nil
+				break
+			}
+
+			slot.Services = append(slot.Services, part)
+			cursor = part.End
+		}
+
+		if slot != nil {
+			slot.End = cursor
+			slots = append(slots, slot)
+		}
+	}
+
+	return slots, nil
+}
+
+func minTime(a, b time.Time) time.Time {
+	if a.Before(b) {
+		return a
+	}
+	return b
+}
+
+func maxTime(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
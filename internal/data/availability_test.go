@@ -0,0 +1,94 @@
+package data
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFreeSlotsAcrossClockChanges(t *testing.T) {
+	newYork := mustLoadLocation(t, "America/New_York")
+
+	hours := timeRange{
+		Start: mustParseLocalTime(t, "00:00:00"),
+		End:   mustParseLocalTime(t, "04:00:00"),
+	}
+
+	tests := []struct {
+		name string
+		day  time.Time
+		want []string
+	}{
+		{
+			// 02:00 to 03:00 does not happen, so four hours on the clock
+			// are only three hours long.
+			name: "spring forward",
+			day:  time.Date(2025, 3, 9, 0, 0, 0, 0, newYork),
+			want: []string{"00:00 EST", "01:00 EST", "03:00 EDT"},
+		},
+		{
+			// 01:00 to 02:00 happens twice, so four hours on the clock are
+			// five hours long.
+			name: "fall back",
+			day:  time.Date(2025, 11, 2, 0, 0, 0, 0, newYork),
+			want: []string{"00:00 EDT", "01:00 EDT", "01:00 EST", "02:00 EST", "03:00 EST"},
+		},
+		{
+			name: "no clock change",
+			day:  time.Date(2025, 11, 9, 0, 0, 0, 0, newYork),
+			want: []string{"00:00 EST", "01:00 EST", "02:00 EST", "03:00 EST"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			open := []Interval{hours.on(tt.day)}
+
+			starts := freeSlots(open, nil, timing{Duration: time.Hour}, time.Hour)
+
+			if len(starts) != len(tt.want) {
+				t.Fatalf("got %d slots %v, want %d", len(starts), starts, len(tt.want))
+			}
+
+			for i, start := range starts {
+				if got := start.Format("15:04 MST"); got != tt.want[i] {
+					t.Errorf("slot %d starts at %s, want %s", i, got, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestFreeSlotsKeepsBuffersAcrossClockChanges(t *testing.T) {
+	newYork := mustLoadLocation(t, "America/New_York")
+	day := time.Date(2025, 3, 9, 0, 0, 0, 0, newYork)
+
+	hours := timeRange{
+		Start: mustParseLocalTime(t, "01:00:00"),
+		End:   mustParseLocalTime(t, "05:00:00"),
+	}
+
+	// A booking from 03:00 to 04:00 EDT, straight after the clocks go
+	// forward.
+	busy := []Interval{{
+		Start: time.Date(2025, 3, 9, 3, 0, 0, 0, newYork),
+		End:   time.Date(2025, 3, 9, 4, 0, 0, 0, newYork),
+	}}
+
+	starts := freeSlots([]Interval{hours.on(day)}, busy, timing{Duration: 30 * time.Minute, After: 15 * time.Minute}, slotStep)
+
+	var got []string
+	for _, start := range starts {
+		got = append(got, start.Format("15:04 MST"))
+	}
+
+	want := []string{"01:00 EST", "01:15 EST", "04:00 EDT", "04:15 EDT"}
+
+	if len(got) != len(want) {
+		t.Fatalf("got slots %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("slot %d starts at %s, want %s", i, got[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,255 @@
+package data
+
+import (
+	"cmp"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+)
+
+const (
+	// calendarFeedHistory is how far back a calendar feed reaches, so that
+	// appointments do not vanish from a calendar as soon as they are over.
+	calendarFeedHistory = 30 * 24 * time.Hour
+
+	// maxCalendarFeedEntries caps the number of appointments in a feed.
+	// Upcoming appointments take precedence over past ones when there are
+	// more than that.
+	maxCalendarFeedEntries = 500
+)
+
+type CalendarFeedModel struct {
+	DB *sql.DB
+}
+
+// CalendarEntry is an appointment as shown in a calendar feed.
+type CalendarEntry struct {
+	AppointmentID int64
+	StartTime     time.Time
+	EndTime       time.Time
+	Status        AppointmentStatus
+	ServiceName   string
+	StaffName     string
+	ProviderName  string
+	Address       string
+	ClientName    string
+}
+
+// CalendarFeed is what a feed token gives access to: the appointments of the
+// client or provider who created it, or of one of the provider's staff
+// members.
+type CalendarFeed struct {
+	Role    Role
+	StaffID *int64
+	Entries []*CalendarEntry
+}
+
+// New creates a feed token for the user, or for one of their staff members if
+// staffID is set, replacing any feed created for the same calendar before.
+func (m CalendarFeedModel) New(userID int64, staffID *int64, ttl time.Duration) (token *Token, err error) {
+	token, err = generateToken(userID, ttl, ScopeCalendarFeed)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		} else if err != nil {
+			tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	_, err = tx.ExecContext(ctx, revokeCalendarFeedQuery, ScopeCalendarFeed, userID, staffID)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		INSERT INTO tokens (hash, user_id, expiry, scope, staff_id)
+		VALUES ($1, $2, $3, $4, $5)
+	`
+
+	args := []any{token.Hash, token.UserID, token.Expiry, token.Scope, staffID}
+
+	_, err = tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return token, nil
+}
+
+const revokeCalendarFeedQuery = `
+	DELETE FROM tokens
+	WHERE scope = $1 AND user_id = $2 AND staff_id IS NOT DISTINCT FROM $3
+`
+
+// Revoke deletes the user's feed token, or that of one of their staff members
+// if staffID is set, so that its URL stops working.
+func (m CalendarFeedModel) Revoke(userID int64, staffID *int64) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, revokeCalendarFeedQuery, ScopeCalendarFeed, userID, staffID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// GetForToken returns the feed a token gives access to, with the appointments
+// that ended within calendarFeedHistory or are still to come. Cancelled
+// appointments are included so that calendars can remove them.
+func (m CalendarFeedModel) GetForToken(tokenPlaintext string) (*CalendarFeed, error) {
+	tokenHash := sha256.Sum256([]byte(tokenPlaintext))
+
+	query := `
+		SELECT t.user_id, u.role, t.staff_id, p.id
+		FROM tokens t
+		INNER JOIN users u ON u.id = t.user_id
+		LEFT JOIN providers p ON p.user_id = u.id
+		WHERE t.hash = $1
+		AND t.scope = $2
+		AND t.expiry > NOW()
+	`
+
+	var (
+		feed       CalendarFeed
+		userID     int64
+		providerID *int64
+	)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, tokenHash[:], ScopeCalendarFeed).Scan(&userID, &feed.Role, &feed.StaffID, &providerID)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	var (
+		column string
+		id     int64
+	)
+
+	switch {
+	case feed.StaffID != nil:
+		column, id = "staff_id", *feed.StaffID
+	case feed.Role == RoleProvider && providerID != nil:
+		column, id = "provider_id", *providerID
+	case feed.Role == RoleClient:
+		column, id = "client_id", userID
+	default:
+		feed.Entries = make([]*CalendarEntry, 0)
+		return &feed, nil
+	}
+
+	feed.Entries, err = m.entries(ctx, column, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return &feed, nil
+}
+
+// entries lists the appointments whose owner column matches id, in the order
+// they start. When there are more than maxCalendarFeedEntries, the ones kept
+// are the upcoming appointments nearest to now and, with whatever room is
+// left, the most recent past ones. The column is never taken from user input,
+// so it is safe to interpolate.
+func (m CalendarFeedModel) entries(ctx context.Context, column string, id int64) ([]*CalendarEntry, error) {
+	query := fmt.Sprintf(`
+		SELECT a.id, a.start_time, a.end_time, a.status,
+			s.name, st.name, p.name, COALESCE(p.address, ''),
+			COALESCE(NULLIF(CONCAT_WS(' ', u.first_name, u.last_name), ''), u.email)
+		FROM appointments a
+		INNER JOIN services s ON s.id = a.service_id
+		INNER JOIN staff st ON st.id = a.staff_id
+		INNER JOIN providers p ON p.id = a.provider_id
+		INNER JOIN users u ON u.id = a.client_id
+		WHERE a.%s = $1
+		AND a.status <> 'held'
+		AND a.end_time > $2
+		ORDER BY a.end_time <= $3,
+			CASE WHEN a.end_time > $3 THEN a.start_time END,
+			a.start_time DESC,
+			a.id
+		LIMIT $4
+	`, column)
+
+	now := time.Now()
+	since := now.Add(-calendarFeedHistory)
+
+	rows, err := m.DB.QueryContext(ctx, query, id, since, now, maxCalendarFeedEntries)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := make([]*CalendarEntry, 0)
+
+	for rows.Next() {
+		var e CalendarEntry
+
+		err := rows.Scan(
+			&e.AppointmentID,
+			&e.StartTime,
+			&e.EndTime,
+			&e.Status,
+			&e.ServiceName,
+			&e.StaffName,
+			&e.ProviderName,
+			&e.Address,
+			&e.ClientName,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		entries = append(entries, &e)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	slices.SortFunc(entries, func(a, b *CalendarEntry) int {
+		if c := a.StartTime.Compare(b.StartTime); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.AppointmentID, b.AppointmentID)
+	})
+
+	return entries, nil
+}
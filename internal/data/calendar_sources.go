@@ -0,0 +1,202 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/url"
+	"slices"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/tormgibbs/snapluks-backend/internal/validator"
+)
+
+type CalendarSourceModel struct {
+	DB *sql.DB
+}
+
+// CalendarSource is an external calendar that a staff member's busy times are
+// imported from.
+type CalendarSource struct {
+	ID           int64      `json:"id"`
+	StaffID      int64      `json:"staff_id"`
+	ProviderID   int64      `json:"-"`
+	URL          string     `json:"url"`
+	LastSyncedAt *time.Time `json:"last_synced_at"`
+	LastError    *string    `json:"last_error"`
+	CreatedAt    time.Time  `json:"created_at"`
+}
+
+// ValidateCalendarSource checks the source's URL. Calendars are only ever
+// fetched over the network, never read from the server's disk.
+func ValidateCalendarSource(v *validator.Validator, s *CalendarSource) {
+	v.Check(s.URL != "", "url", "must be provided")
+	v.Check(len(s.URL) <= 2048, "url", "must not be more than 2048 bytes long")
+
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		v.AddError("url", "must be a valid URL")
+		return
+	}
+
+	v.Check(slices.Contains([]string{"http", "https", "webcal"}, u.Scheme), "url", "must be an http, https or webcal URL")
+}
+
+func (m CalendarSourceModel) Insert(s *CalendarSource) error {
+	query := `
+		INSERT INTO calendar_sources (staff_id, provider_id, url)
+		VALUES ($1, $2, $3)
+		RETURNING id, created_at
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, s.StaffID, s.ProviderID, s.URL).Scan(&s.ID, &s.CreatedAt)
+	if err != nil {
+		if pgErr, ok := err.(*pgconn.PgError); ok && pgErr.Code == "23505" {
+			return ErrDuplicateRecord
+		}
+		return err
+	}
+
+	return nil
+}
+
+func (m CalendarSourceModel) Get(id, staffID int64) (*CalendarSource, error) {
+	query := `
+		SELECT id, staff_id, provider_id, url, last_synced_at, last_error, created_at
+		FROM calendar_sources
+		WHERE id = $1 AND staff_id = $2
+	`
+
+	var s CalendarSource
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, id, staffID).Scan(
+		&s.ID,
+		&s.StaffID,
+		&s.ProviderID,
+		&s.URL,
+		&s.LastSyncedAt,
+		&s.LastError,
+		&s.CreatedAt,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &s, nil
+}
+
+func (m CalendarSourceModel) GetAllForStaff(staffID int64) ([]*CalendarSource, error) {
+	query := `
+		SELECT id, staff_id, provider_id, url, last_synced_at, last_error, created_at
+		FROM calendar_sources
+		WHERE staff_id = $1
+		ORDER BY created_at, id
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, staffID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sources := make([]*CalendarSource, 0)
+
+	for rows.Next() {
+		var s CalendarSource
+
+		err := rows.Scan(
+			&s.ID,
+			&s.StaffID,
+			&s.ProviderID,
+			&s.URL,
+			&s.LastSyncedAt,
+			&s.LastError,
+			&s.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		sources = append(sources, &s)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return sources, nil
+}
+
+// RecordSync notes that the source has just been synced, and the error that
+// stopped its calendar being imported, if any.
+func (m CalendarSourceModel) RecordSync(s *CalendarSource, syncErr error) error {
+	query := `
+		UPDATE calendar_sources
+		SET last_synced_at = NOW(), last_error = $1
+		WHERE id = $2
+		RETURNING last_synced_at, last_error
+	`
+
+	var lastError *string
+	if syncErr != nil {
+		msg := syncErr.Error()
+		lastError = &msg
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, lastError, s.ID).Scan(&s.LastSyncedAt, &s.LastError)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Delete removes the source along with the busy times imported from it.
+func (m CalendarSourceModel) Delete(id, staffID int64) error {
+	query := `
+		DELETE FROM calendar_sources
+		WHERE id = $1 AND staff_id = $2
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, id, staffID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,147 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var ErrSessionFull = errors.New("session full")
+
+// serviceCapacity looks up how many clients a staff member can serve at once
+// for the service. Services with a capacity above one are run as classes.
+func serviceCapacity(ctx context.Context, q queryer, serviceID int64) (int, error) {
+	query := `
+		SELECT capacity
+		FROM services
+		WHERE id = $1
+	`
+
+	var capacity int
+
+	err := q.QueryRowContext(ctx, query, serviceID).Scan(&capacity)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return 0, ErrServiceNotFound
+		default:
+			return 0, err
+		}
+	}
+
+	return capacity, nil
+}
+
+// sessionKey identifies a session of a class: every appointment for the same
+// service with the same staff member at the same start time.
+func sessionKey(a *Appointment) string {
+	return fmt.Sprintf("%d/%d/%d", a.ServiceID, a.StaffID, a.StartTime.Unix())
+}
+
+// claimSeat gives an appointment for a class the key of its session, which
+// lets it share the staff member's time with the rest of the session, and
+// makes sure the session has a seat left. The service row is locked first so
+// that concurrent bookings of the class are counted one after the other.
+// Appointments for any other service are left without a key.
+func claimSeat(ctx context.Context, q queryer, a *Appointment) error {
+	a.SessionKey = nil
+
+	capacity, err := serviceCapacity(ctx, q, a.ServiceID)
+	if err != nil {
+		return err
+	}
+
+	if capacity == 1 {
+		return nil
+	}
+
+	query := `
+		SELECT capacity
+		FROM services
+		WHERE id = $1
+		FOR NO KEY UPDATE
+	`
+
+	err = q.QueryRowContext(ctx, query, a.ServiceID).Scan(&capacity)
+	if err != nil {
+		return err
+	}
+
+	key := sessionKey(a)
+
+	query = `
+		SELECT count(*)
+		FROM appointments
+		WHERE session_key = $1
+		AND status <> 'cancelled'
+		AND id <> $2
+	`
+
+	var booked int
+
+	err = q.QueryRowContext(ctx, query, key, a.ID).Scan(&booked)
+	if err != nil {
+		return err
+	}
+
+	if booked >= capacity {
+		return ErrSessionFull
+	}
+
+	a.SessionKey = &key
+
+	return nil
+}
+
+// session is a start time of a class and the number of seats booked at it.
+type session struct {
+	Start  time.Time
+	Booked int
+}
+
+// sessionBookings returns, per staff member, the sessions of the class that
+// start within the window.
+func sessionBookings(ctx context.Context, q queryer, serviceID int64, staffIDs []int64, window Interval) (map[int64][]session, error) {
+	query := `
+		SELECT staff_id, start_time, count(*)
+		FROM appointments
+		WHERE staff_id = ANY($1)
+		AND service_id = $2
+		AND session_key IS NOT NULL
+		AND status <> 'cancelled'
+		AND start_time >= $3
+		AND start_time < $4
+		GROUP BY staff_id, start_time
+		ORDER BY start_time
+	`
+
+	rows, err := q.QueryContext(ctx, query, staffIDs, serviceID, window.Start, window.End)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := make(map[int64][]session)
+
+	for rows.Next() {
+		var (
+			staffID int64
+			s       session
+		)
+
+		err := rows.Scan(&staffID, &s.Start, &s.Booked)
+		if err != nil {
+			return nil, err
+		}
+
+		sessions[staffID] = append(sessions[staffID], s)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return sessions, nil
+}
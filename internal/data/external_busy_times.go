@@ -0,0 +1,145 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+type ExternalBusyTimeModel struct {
+	DB *sql.DB
+}
+
+// ExternalBusyTime is a period during which a staff member is busy according
+// to a calendar kept outside Snapluks. It blocks availability like time off.
+type ExternalBusyTime struct {
+	ID         int64     `json:"id"`
+	StaffID    int64     `json:"staff_id"`
+	ProviderID int64     `json:"-"`
+	SourceID   *int64    `json:"source_id,omitempty"`
+	UID        string    `json:"uid,omitempty"`
+	StartTime  time.Time `json:"start_time"`
+	EndTime    time.Time `json:"end_time"`
+}
+
+// Replace swaps the busy times previously imported for the staff member from
+// the source, or from an uploaded file if sourceID is nil, for the given ones.
+func (m ExternalBusyTimeModel) Replace(staffID, providerID int64, sourceID *int64, busy []*ExternalBusyTime) (err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		} else if err != nil {
+			tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	query := `
+		DELETE FROM external_busy_times
+		WHERE staff_id = $1 AND source_id IS NOT DISTINCT FROM $2
+	`
+
+	_, err = tx.ExecContext(ctx, query, staffID, sourceID)
+	if err != nil {
+		return err
+	}
+
+	if len(busy) == 0 {
+		return nil
+	}
+
+	uids := make([]string, len(busy))
+	starts := make([]time.Time, len(busy))
+	ends := make([]time.Time, len(busy))
+
+	for i, b := range busy {
+		uids[i], starts[i], ends[i] = b.UID, b.StartTime, b.EndTime
+	}
+
+	// The rows are inserted in a single statement, since a recurring event
+	// alone can expand to hundreds of them.
+	query = `
+		INSERT INTO external_busy_times (staff_id, provider_id, source_id, uid, start_time, end_time)
+		SELECT $1, $2, $3, b.uid, b.start_time, b.end_time
+		FROM unnest($4::text[], $5::timestamptz[], $6::timestamptz[]) AS b(uid, start_time, end_time)
+	`
+
+	_, err = tx.ExecContext(ctx, query, staffID, providerID, sourceID, uids, starts, ends)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// GetAllForStaff returns the staff member's busy times that overlap the window.
+func (m ExternalBusyTimeModel) GetAllForStaff(staffID int64, window Interval) ([]*ExternalBusyTime, error) {
+	query := `
+		SELECT id, staff_id, provider_id, source_id, uid, start_time, end_time
+		FROM external_busy_times
+		WHERE staff_id = $1
+		AND start_time < $3
+		AND end_time > $2
+		ORDER BY start_time, id
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, staffID, window.Start, window.End)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	busy := make([]*ExternalBusyTime, 0)
+
+	for rows.Next() {
+		var b ExternalBusyTime
+
+		err := rows.Scan(
+			&b.ID,
+			&b.StaffID,
+			&b.ProviderID,
+			&b.SourceID,
+			&b.UID,
+			&b.StartTime,
+			&b.EndTime,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		busy = append(busy, &b)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return busy, nil
+}
+
+// externalBusyIntervals returns, per staff member, the busy times imported
+// from external calendars that overlap the window.
+func externalBusyIntervals(ctx context.Context, q queryer, staffIDs []int64, window Interval) (map[int64][]Interval, error) {
+	query := `
+		SELECT staff_id, start_time, end_time
+		FROM external_busy_times
+		WHERE staff_id = ANY($1)
+		AND start_time < $3
+		AND end_time > $2
+	`
+
+	return staffIntervals(ctx, q, query, staffIDs, window)
+}
@@ -63,3 +63,8 @@ func (lt *LocalTime) Scan(value interface{}) error {
 		return fmt.Errorf("cannot scan type %T into LocalTime", value)
 	}
 }
+
+// On returns the wall clock time of lt on the given day, in the day's location.
+func (lt LocalTime) On(day time.Time) time.Time {
+	return time.Date(day.Year(), day.Month(), day.Day(), lt.Hour(), lt.Minute(), lt.Second(), 0, day.Location())
+}
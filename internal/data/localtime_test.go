@@ -0,0 +1,63 @@
+package data
+
+import (
+	"testing"
+	"time"
+)
+
+func mustParseLocalTime(t *testing.T, s string) LocalTime {
+	t.Helper()
+	v, err := time.Parse("15:04:05", s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return LocalTime{v}
+}
+
+func mustLoadLocation(t *testing.T, name string) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return loc
+}
+
+func TestLocalTimeOn(t *testing.T) {
+	newYork := mustLoadLocation(t, "America/New_York")
+	accra := mustLoadLocation(t, "Africa/Accra")
+
+	tests := []struct {
+		name string
+		lt   string
+		day  time.Time
+		want string
+	}{
+		{"no clock change", "09:30:00", time.Date(2025, 6, 2, 0, 0, 0, 0, accra), "2025-06-02T09:30:00Z"},
+		{"winter time", "09:00:00", time.Date(2025, 1, 15, 0, 0, 0, 0, newYork), "2025-01-15T14:00:00Z"},
+		{"summer time", "09:00:00", time.Date(2025, 7, 15, 0, 0, 0, 0, newYork), "2025-07-15T13:00:00Z"},
+		{"before spring forward", "01:00:00", time.Date(2025, 3, 9, 0, 0, 0, 0, newYork), "2025-03-09T06:00:00Z"},
+		{"after spring forward", "09:00:00", time.Date(2025, 3, 9, 0, 0, 0, 0, newYork), "2025-03-09T13:00:00Z"},
+		{"before fall back", "00:30:00", time.Date(2025, 11, 2, 0, 0, 0, 0, newYork), "2025-11-02T04:30:00Z"},
+		{"after fall back", "09:00:00", time.Date(2025, 11, 2, 0, 0, 0, 0, newYork), "2025-11-02T14:00:00Z"},
+		{"day given at a later hour", "09:00:00", time.Date(2025, 11, 2, 23, 0, 0, 0, newYork), "2025-11-02T14:00:00Z"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := mustParseLocalTime(t, tt.lt).On(tt.day)
+
+			want, err := time.Parse(time.RFC3339, tt.want)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !got.Equal(want) {
+				t.Errorf("got %s, want %s", got.UTC().Format(time.RFC3339), tt.want)
+			}
+			if got.Location() != tt.day.Location() {
+				t.Errorf("got location %s, want %s", got.Location(), tt.day.Location())
+			}
+		})
+	}
+}
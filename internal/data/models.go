@@ -12,27 +12,61 @@ var (
 )
 
 type Models struct {
-	Users                   UserModel
-	Providers               ProviderModel
-	Tokens                  TokenModel
-	Categories              CategoryModel
-	Services                ServiceModel
-	Staff                   StaffModel
-	ProviderImages          ProviderImageModel
-	ProviderBusinessHours   ProviderBusinessHoursModel
-	EmailVerificationTokens EmailVerificationTokenModel
+	Users                    UserModel
+	Providers                ProviderModel
+	Tokens                   TokenModel
+	Categories               CategoryModel
+	Services                 ServiceModel
+	Staff                    StaffModel
+	ProviderImages           ProviderImageModel
+	ProviderBusinessHours    ProviderBusinessHoursModel
+	EmailVerificationTokens  EmailVerificationTokenModel
+	Appointments             AppointmentModel
+	Availability             AvailabilityModel
+	AppointmentStatusChanges AppointmentStatusChangeModel
+	AppointmentReschedules   AppointmentRescheduleModel
+	StaffSchedules           StaffScheduleModel
+	StaffTimeOff             StaffTimeOffModel
+	ProviderHourExceptions   ProviderHourExceptionModel
+	AppointmentSeries        AppointmentSeriesModel
+	AppointmentGroups        AppointmentGroupModel
+	Waitlist                 WaitlistModel
+	WalkIns                  WalkInModel
+	Resources                ResourceModel
+	CalendarFeeds            CalendarFeedModel
+	CalendarSources          CalendarSourceModel
+	ExternalBusyTimes        ExternalBusyTimeModel
+	Sessions                 SessionModel
+	AuthThrottles            AuthThrottleModel
 }
 
 func NewModels(DB *sql.DB) Models {
 	return Models{
-		Users:                   UserModel{DB},
-		Providers:               ProviderModel{DB},
-		Tokens:                  TokenModel{DB},
-		Services:                ServiceModel{DB},
-		Staff:                   StaffModel{DB},
-		Categories:              CategoryModel{DB},
-		ProviderImages:          ProviderImageModel{DB},
-		ProviderBusinessHours:   ProviderBusinessHoursModel{DB},
-		EmailVerificationTokens: EmailVerificationTokenModel{DB},
+		Users:                    UserModel{DB},
+		Providers:                ProviderModel{DB},
+		Tokens:                   TokenModel{DB},
+		Services:                 ServiceModel{DB},
+		Staff:                    StaffModel{DB},
+		Categories:               CategoryModel{DB},
+		ProviderImages:           ProviderImageModel{DB},
+		ProviderBusinessHours:    ProviderBusinessHoursModel{DB},
+		EmailVerificationTokens:  EmailVerificationTokenModel{DB},
+		Appointments:             AppointmentModel{DB},
+		Availability:             AvailabilityModel{DB},
+		AppointmentStatusChanges: AppointmentStatusChangeModel{DB},
+		AppointmentReschedules:   AppointmentRescheduleModel{DB},
+		StaffSchedules:           StaffScheduleModel{DB},
+		StaffTimeOff:             StaffTimeOffModel{DB},
+		ProviderHourExceptions:   ProviderHourExceptionModel{DB},
+		AppointmentSeries:        AppointmentSeriesModel{DB},
+		AppointmentGroups:        AppointmentGroupModel{DB},
+		Waitlist:                 WaitlistModel{DB},
+		WalkIns:                  WalkInModel{DB},
+		Resources:                ResourceModel{DB},
+		CalendarFeeds:            CalendarFeedModel{DB},
+		CalendarSources:          CalendarSourceModel{DB},
+		ExternalBusyTimes:        ExternalBusyTimeModel{DB},
+		Sessions:                 SessionModel{DB},
+		AuthThrottles:            AuthThrottleModel{DB},
 	}
 }
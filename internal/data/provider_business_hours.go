@@ -3,11 +3,16 @@ package data
 import (
 	"context"
 	"database/sql"
+	"errors"
+	"fmt"
 	"time"
 
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/tormgibbs/snapluks-backend/internal/validator"
 )
 
+var ErrOverlappingHours = errors.New("overlapping hours")
+
 type ProviderBusinessHoursModel struct {
 	DB *sql.DB
 }
@@ -21,7 +26,10 @@ type ProviderBusinessHour struct {
 	CloseTime  *LocalTime `json:"close_time,omitempty"`
 }
 
-func ValidateProviderBusinessHour(v *validator.Validator, bh *ProviderBusinessHour) {
+// ValidateProviderBusinessHour checks a single interval of business hours.
+// The provider's other intervals, whether already stored or submitted in the
+// same request, are passed in so that clashes on the same day are reported.
+func ValidateProviderBusinessHour(v *validator.Validator, bh *ProviderBusinessHour, others []*ProviderBusinessHour) {
 	v.Check(bh.ProviderID > 0, "provider_id", "must be provided and greater than zero")
 	v.Check(bh.DayOfWeek >= 0 && bh.DayOfWeek <= 6, "day_of_week", "must be between 0 (Sunday) and 6 (Saturday)")
 
@@ -36,6 +44,85 @@ func ValidateProviderBusinessHour(v *validator.Validator, bh *ProviderBusinessHo
 			v.Check(bh.OpenTime.Before(*bh.CloseTime), "time", "open_time must be before close_time")
 		}
 	}
+
+	for _, o := range others {
+		if o == bh || (o.ID != 0 && o.ID == bh.ID) || o.DayOfWeek != bh.DayOfWeek {
+			continue
+		}
+
+		switch {
+		case bh.IsClosed || o.IsClosed:
+			v.AddError("is_closed", "a closed day must not have any other hours")
+		case bh.overlaps(o):
+			v.AddError("time", "must not overlap other hours on the same day")
+		}
+	}
+}
+
+func (bh *ProviderBusinessHour) overlaps(o *ProviderBusinessHour) bool {
+	if bh.OpenTime == nil || bh.CloseTime == nil || o.OpenTime == nil || o.CloseTime == nil {
+		return false
+	}
+	return bh.OpenTime.Before(*o.CloseTime) && o.OpenTime.Before(*bh.CloseTime)
+}
+
+// ValidateProviderBusinessWeek checks the intervals submitted together in one
+// request. Errors for individual intervals are keyed by their position in the
+// list.
+func ValidateProviderBusinessWeek(v *validator.Validator, hours []*ProviderBusinessHour) {
+	v.Check(len(hours) > 0, "business_hours", "must contain at least one interval")
+	v.Check(len(hours) <= 50, "business_hours", "must not contain more than 50 intervals")
+
+	for i, bh := range hours {
+		dv := validator.New()
+		ValidateProviderBusinessHour(dv, bh, hours)
+
+		for key, msg := range dv.Errors {
+			v.AddError(fmt.Sprintf("business_hours[%d].%s", i, key), msg)
+		}
+	}
+}
+
+// BusinessDay groups the intervals of business hours that fall on the same
+// day of the week.
+type BusinessDay struct {
+	DayOfWeek int                     `json:"day_of_week"`
+	IsClosed  bool                    `json:"is_closed"`
+	Intervals []*ProviderBusinessHour `json:"intervals"`
+}
+
+// GroupBusinessHours groups intervals by day of the week. The input must be
+// ordered by day.
+func GroupBusinessHours(hours []*ProviderBusinessHour) []*BusinessDay {
+	days := make([]*BusinessDay, 0)
+
+	for _, bh := range hours {
+		if len(days) == 0 || days[len(days)-1].DayOfWeek != bh.DayOfWeek {
+			days = append(days, &BusinessDay{DayOfWeek: bh.DayOfWeek, Intervals: []*ProviderBusinessHour{}})
+		}
+
+		day := days[len(days)-1]
+
+		if bh.IsClosed {
+			day.IsClosed = true
+			continue
+		}
+
+		day.Intervals = append(day.Intervals, bh)
+	}
+
+	return days
+}
+
+// isHoursOverlap reports whether err was raised by one of the constraints that
+// keep a provider's intervals on the same day apart.
+func isHoursOverlap(err error) bool {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return false
+	}
+	return (pgErr.Code == "23P01" && pgErr.ConstraintName == "provider_business_hours_no_overlap") ||
+		(pgErr.Code == "23505" && pgErr.ConstraintName == "idx_provider_business_hours_closed")
 }
 
 func (m *ProviderBusinessHoursModel) Insert(bh *ProviderBusinessHour) error {
@@ -48,7 +135,7 @@ func (m *ProviderBusinessHoursModel) Insert(bh *ProviderBusinessHour) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	return m.DB.QueryRowContext(
+	err := m.DB.QueryRowContext(
 		ctx,
 		query,
 		bh.ProviderID,
@@ -57,6 +144,114 @@ func (m *ProviderBusinessHoursModel) Insert(bh *ProviderBusinessHour) error {
 		bh.OpenTime,
 		bh.CloseTime,
 	).Scan(&bh.ID)
+	if err != nil {
+		if isHoursOverlap(err) {
+			return ErrOverlappingHours
+		}
+		return err
+	}
+
+	return nil
+}
+
+// ReplaceDays replaces all of the provider's hours on every day that appears
+// in the list with the given intervals, in a single transaction. Days that are
+// not in the list are left unchanged.
+func (m *ProviderBusinessHoursModel) ReplaceDays(providerID int64, hours []*ProviderBusinessHour) (err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		} else if err != nil {
+			tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	days := make([]int, 0, len(hours))
+	for _, bh := range hours {
+		days = append(days, bh.DayOfWeek)
+	}
+
+	query := `
+		DELETE FROM provider_business_hours
+		WHERE provider_id = $1 AND day_of_week = ANY($2)
+	`
+
+	_, err = tx.ExecContext(ctx, query, providerID, validator.Dedupe(days))
+	if err != nil {
+		return err
+	}
+
+	query = `
+		INSERT INTO provider_business_hours (provider_id, day_of_week, is_closed, open_time, close_time)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id
+	`
+
+	for _, bh := range hours {
+		bh.ProviderID = int(providerID)
+
+		err = tx.QueryRowContext(
+			ctx,
+			query,
+			bh.ProviderID,
+			bh.DayOfWeek,
+			bh.IsClosed,
+			bh.OpenTime,
+			bh.CloseTime,
+		).Scan(&bh.ID)
+		if err != nil {
+			if isHoursOverlap(err) {
+				return ErrOverlappingHours
+			}
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (m *ProviderBusinessHoursModel) Get(id, providerID int64) (*ProviderBusinessHour, error) {
+	query := `
+		SELECT id, provider_id, day_of_week, is_closed, open_time, close_time
+		FROM provider_business_hours
+		WHERE id = $1 AND provider_id = $2
+	`
+
+	var bh ProviderBusinessHour
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, id, providerID).Scan(
+		&bh.ID,
+		&bh.ProviderID,
+		&bh.DayOfWeek,
+		&bh.IsClosed,
+		&bh.OpenTime,
+		&bh.CloseTime,
+	)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &bh, nil
 }
 
 func (m *ProviderBusinessHoursModel) GetAllForProvider(providerID int64) ([]*ProviderBusinessHour, error) {
@@ -64,7 +259,7 @@ func (m *ProviderBusinessHoursModel) GetAllForProvider(providerID int64) ([]*Pro
 		SELECT id, provider_id, day_of_week, is_closed, open_time, close_time
 		FROM provider_business_hours
 		WHERE provider_id = $1
-		ORDER BY day_of_week
+		ORDER BY day_of_week, open_time NULLS FIRST
 	`
 
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
@@ -101,23 +296,70 @@ func (m *ProviderBusinessHoursModel) GetAllForProvider(providerID int64) ([]*Pro
 	return hours, nil
 }
 
-func (m *ProviderBusinessHoursModel) Update(ctx context.Context, bh *ProviderBusinessHour) error {
+func (m *ProviderBusinessHoursModel) Update(bh *ProviderBusinessHour) error {
 	query := `
 		UPDATE provider_business_hours
-		SET is_closed = $1,
-		    open_time = $2,
-		    close_time = $3
-		WHERE id = $4
+		SET day_of_week = $1,
+		    is_closed = $2,
+		    open_time = $3,
+		    close_time = $4
+		WHERE id = $5 AND provider_id = $6
 	`
 
-	_, err := m.DB.ExecContext(
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(
 		ctx,
 		query,
+		bh.DayOfWeek,
 		bh.IsClosed,
 		bh.OpenTime,
 		bh.CloseTime,
 		bh.ID,
+		bh.ProviderID,
 	)
+	if err != nil {
+		if isHoursOverlap(err) {
+			return ErrOverlappingHours
+		}
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+func (m *ProviderBusinessHoursModel) Delete(id, providerID int64) error {
+	query := `
+		DELETE FROM provider_business_hours
+		WHERE id = $1 AND provider_id = $2
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, id, providerID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
 
-	return err
+	return nil
 }
@@ -0,0 +1,271 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/tormgibbs/snapluks-backend/internal/validator"
+)
+
+type ProviderHourExceptionModel struct {
+	DB *sql.DB
+}
+
+// ProviderHourException replaces the provider's weekly business hours on every
+// date from StartDate to EndDate inclusive, either closing the provider or
+// opening it for different hours.
+type ProviderHourException struct {
+	ID         int64      `json:"id"`
+	ProviderID int64      `json:"-"`
+	StartDate  Date       `json:"start_date"`
+	EndDate    Date       `json:"end_date"`
+	IsClosed   bool       `json:"is_closed"`
+	OpenTime   *LocalTime `json:"open_time,omitempty"`
+	CloseTime  *LocalTime `json:"close_time,omitempty"`
+	Reason     *string    `json:"reason,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+func ValidateProviderHourException(v *validator.Validator, e *ProviderHourException) {
+	v.Check(e.ProviderID > 0, "provider_id", "must be provided and greater than zero")
+
+	v.Check(!e.StartDate.IsZero(), "start_date", "must be provided")
+	v.Check(!e.EndDate.IsZero(), "end_date", "must be provided")
+	v.Check(!e.EndDate.Before(e.StartDate.Time), "end_date", "must not be before start_date")
+	v.Check(e.EndDate.Sub(e.StartDate.Time) <= 366*24*time.Hour, "end_date", "must not be more than a year after start_date")
+
+	if e.IsClosed {
+		v.Check(e.OpenTime == nil, "open_time", "must be null when is_closed is true")
+		v.Check(e.CloseTime == nil, "close_time", "must be null when is_closed is true")
+	} else {
+		v.Check(e.OpenTime != nil, "open_time", "must be provided when is_closed is false")
+		v.Check(e.CloseTime != nil, "close_time", "must be provided when is_closed is false")
+
+		if e.OpenTime != nil && e.CloseTime != nil {
+			v.Check(e.OpenTime.Before(*e.CloseTime), "time", "open_time must be before close_time")
+		}
+	}
+
+	if e.Reason != nil {
+		v.Check(len(*e.Reason) <= 500, "reason", "must not be more than 500 bytes long")
+	}
+}
+
+// isExceptionOverlap reports whether err was raised by the constraint that
+// stops two exceptions of the same provider from covering the same date.
+func isExceptionOverlap(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23P01" && pgErr.ConstraintName == "provider_hour_exceptions_no_overlap"
+}
+
+// exceptionHours returns the exception covering the given date, or nil if the
+// provider keeps its weekly hours that day.
+func exceptionHours(ctx context.Context, q queryer, providerID int64, date Date) (*ProviderHourException, error) {
+	query := `
+		SELECT is_closed, open_time, close_time
+		FROM provider_hour_exceptions
+		WHERE provider_id = $1 AND $2::date BETWEEN start_date AND end_date
+	`
+
+	var e ProviderHourException
+
+	err := q.QueryRowContext(ctx, query, providerID, date).Scan(&e.IsClosed, &e.OpenTime, &e.CloseTime)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, nil
+		default:
+			return nil, err
+		}
+	}
+
+	return &e, nil
+}
+
+func (m ProviderHourExceptionModel) Insert(e *ProviderHourException) error {
+	query := `
+		INSERT INTO provider_hour_exceptions (provider_id, start_date, end_date, is_closed, open_time, close_time, reason)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id, created_at
+	`
+
+	args := []any{
+		e.ProviderID,
+		e.StartDate,
+		e.EndDate,
+		e.IsClosed,
+		e.OpenTime,
+		e.CloseTime,
+		e.Reason,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&e.ID, &e.CreatedAt)
+	if err != nil {
+		if isExceptionOverlap(err) {
+			return ErrDuplicateRecord
+		}
+		return err
+	}
+
+	return nil
+}
+
+func (m ProviderHourExceptionModel) Get(id, providerID int64) (*ProviderHourException, error) {
+	query := `
+		SELECT id, provider_id, start_date, end_date, is_closed, open_time, close_time, reason, created_at
+		FROM provider_hour_exceptions
+		WHERE id = $1 AND provider_id = $2
+	`
+
+	var e ProviderHourException
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, id, providerID).Scan(
+		&e.ID,
+		&e.ProviderID,
+		&e.StartDate,
+		&e.EndDate,
+		&e.IsClosed,
+		&e.OpenTime,
+		&e.CloseTime,
+		&e.Reason,
+		&e.CreatedAt,
+	)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &e, nil
+}
+
+// GetAllForProvider returns the provider's exceptions that have not yet ended.
+func (m ProviderHourExceptionModel) GetAllForProvider(providerID int64) ([]*ProviderHourException, error) {
+	query := `
+		SELECT id, provider_id, start_date, end_date, is_closed, open_time, close_time, reason, created_at
+		FROM provider_hour_exceptions
+		WHERE provider_id = $1 AND end_date >= CURRENT_DATE
+		ORDER BY start_date
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, providerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	exceptions := make([]*ProviderHourException, 0)
+
+	for rows.Next() {
+		var e ProviderHourException
+		err := rows.Scan(
+			&e.ID,
+			&e.ProviderID,
+			&e.StartDate,
+			&e.EndDate,
+			&e.IsClosed,
+			&e.OpenTime,
+			&e.CloseTime,
+			&e.Reason,
+			&e.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		exceptions = append(exceptions, &e)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return exceptions, nil
+}
+
+func (m ProviderHourExceptionModel) Update(e *ProviderHourException) error {
+	query := `
+		UPDATE provider_hour_exceptions
+		SET start_date = $1,
+			end_date = $2,
+			is_closed = $3,
+			open_time = $4,
+			close_time = $5,
+			reason = $6
+		WHERE id = $7 AND provider_id = $8
+	`
+
+	args := []any{
+		e.StartDate,
+		e.EndDate,
+		e.IsClosed,
+		e.OpenTime,
+		e.CloseTime,
+		e.Reason,
+		e.ID,
+		e.ProviderID,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, args...)
+	if err != nil {
+		if isExceptionOverlap(err) {
+			return ErrDuplicateRecord
+		}
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+func (m ProviderHourExceptionModel) Delete(id, providerID int64) error {
+	query := `
+		DELETE FROM provider_hour_exceptions
+		WHERE id = $1 AND provider_id = $2
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, id, providerID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
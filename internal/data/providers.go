@@ -28,6 +28,29 @@ type Provider struct {
 	Address     string  `json:"address,omitempty"`
 	LogoURL     string  `json:"logo_url,omitempty"`
 	CoverURL    string  `json:"cover_url,omitempty"`
+	Timezone    string  `json:"timezone"`
+
+	CancellationPolicy CancellationPolicy `json:"cancellation_policy"`
+}
+
+// CancellationPolicy holds the limits a provider places on changes to a
+// booking. A nil field means the provider does not impose that limit.
+type CancellationPolicy struct {
+	NoticeHours    *int `json:"cancellation_notice_hours"`
+	MaxReschedules *int `json:"max_reschedules"`
+}
+
+// withinNotice reports whether a change made at now falls inside the notice
+// period before an appointment starting at start.
+func (cp CancellationPolicy) withinNotice(start, now time.Time) bool {
+	if cp.NoticeHours == nil {
+		return false
+	}
+	return now.Add(time.Duration(*cp.NoticeHours) * time.Hour).After(start)
+}
+
+func (cp CancellationPolicy) rescheduleLimitReached(count int) bool {
+	return cp.MaxReschedules != nil && count >= *cp.MaxReschedules
 }
 
 func ValidateProvider(v *validator.Validator, p *Provider) {
@@ -42,6 +65,65 @@ func ValidateProvider(v *validator.Validator, p *Provider) {
 
 	v.Check(p.Description != "", "description", "must be provided")
 	v.Check(len(p.Description) <= 10000, "description", "must be not be more than 10000 bytes long")
+
+	v.Check(validTimezone(p.Timezone), "timezone", "must be a valid IANA time zone (e.g. 'Africa/Accra')")
+
+	ValidateCancellationPolicy(v, p.CancellationPolicy)
+}
+
+func validTimezone(name string) bool {
+	if name == "" || name == "Local" {
+		return false
+	}
+	_, err := time.LoadLocation(name)
+	return err == nil
+}
+
+// Location returns the provider's time zone, in which its business hours and
+// schedules are expressed. The time zone is validated when it is saved, so an
+// error means the stored value can no longer be loaded, and times must not be
+// worked out in some other zone in its place.
+func (p *Provider) Location() (*time.Location, error) {
+	loc, err := time.LoadLocation(p.Timezone)
+	if err != nil {
+		return nil, fmt.Errorf("loading time zone of provider %d: %w", p.ID, err)
+	}
+	return loc, nil
+}
+
+// providerLocation looks up the time zone of a provider.
+func providerLocation(ctx context.Context, q queryer, providerID int64) (*time.Location, error) {
+	query := `
+		SELECT timezone
+		FROM providers
+		WHERE id = $1
+	`
+
+	p := Provider{ID: providerID}
+
+	err := q.QueryRowContext(ctx, query, providerID).Scan(&p.Timezone)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return p.Location()
+}
+
+func ValidateCancellationPolicy(v *validator.Validator, cp CancellationPolicy) {
+	if cp.NoticeHours != nil {
+		v.Check(*cp.NoticeHours >= 0, "cancellation_notice_hours", "must not be negative")
+		v.Check(*cp.NoticeHours <= 720, "cancellation_notice_hours", "must not be more than 720 hours")
+	}
+
+	if cp.MaxReschedules != nil {
+		v.Check(*cp.MaxReschedules >= 0, "max_reschedules", "must not be negative")
+		v.Check(*cp.MaxReschedules <= 100, "max_reschedules", "must not be more than 100")
+	}
 }
 
 func (m *ProviderModel) Insert(p *Provider, u *User) error {
@@ -66,8 +148,8 @@ func (m *ProviderModel) Insert(p *Provider, u *User) error {
 	}()
 
 	query := `
-		INSERT INTO providers (user_id, provider_type_id, name, email, phone_number, description)
-		VALUES ($1, $2, $3, $4, $5, $6)
+		INSERT INTO providers (user_id, provider_type_id, name, email, phone_number, description, timezone)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
 		RETURNING id;
 	`
 
@@ -78,6 +160,7 @@ func (m *ProviderModel) Insert(p *Provider, u *User) error {
 		p.Email,
 		p.PhoneNumber,
 		p.Description,
+		p.Timezone,
 	}
 
 	err = tx.QueryRowContext(ctx, query, args...).Scan(&p.ID)
@@ -110,7 +193,8 @@ func (m *ProviderModel) Insert(p *Provider, u *User) error {
 
 func (m ProviderModel) GetByUserID(userID int64) (*Provider, error) {
 	query := `
-		SELECT id, user_id, provider_type_id, name, email, phone_number, description
+		SELECT id, user_id, provider_type_id, name, email, phone_number, description,
+			cancellation_notice_hours, max_reschedules, timezone
 		FROM providers
 		WHERE user_id = $1
 	`
@@ -127,6 +211,46 @@ func (m ProviderModel) GetByUserID(userID int64) (*Provider, error) {
 		&provider.Email,
 		&provider.PhoneNumber,
 		&provider.Description,
+		&provider.CancellationPolicy.NoticeHours,
+		&provider.CancellationPolicy.MaxReschedules,
+		&provider.Timezone,
+	)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &provider, nil
+}
+
+func (m ProviderModel) Get(id int64) (*Provider, error) {
+	query := `
+		SELECT id, user_id, provider_type_id, name, email, phone_number, description,
+			cancellation_notice_hours, max_reschedules, timezone
+		FROM providers
+		WHERE id = $1
+	`
+	var provider Provider
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(
+		&provider.ID,
+		&provider.UserID,
+		&provider.TypeID,
+		&provider.Name,
+		&provider.Email,
+		&provider.PhoneNumber,
+		&provider.Description,
+		&provider.CancellationPolicy.NoticeHours,
+		&provider.CancellationPolicy.MaxReschedules,
+		&provider.Timezone,
 	)
 
 	if err != nil {
@@ -149,8 +273,11 @@ func (m ProviderModel) Update(p *Provider) error {
 			phone_number = $3,
 			description = $4,
 			logo_url = $5,
-			cover_url = $6
-		WHERE id = $7
+			cover_url = $6,
+			cancellation_notice_hours = $7,
+			max_reschedules = $8,
+			timezone = $9
+		WHERE id = $10
 	`
 
 	args := []any{
@@ -160,6 +287,9 @@ func (m ProviderModel) Update(p *Provider) error {
 		p.Description,
 		p.LogoURL,
 		p.CoverURL,
+		p.CancellationPolicy.NoticeHours,
+		p.CancellationPolicy.MaxReschedules,
+		p.Timezone,
 		p.ID,
 	}
 
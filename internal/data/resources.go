@@ -0,0 +1,538 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/tormgibbs/snapluks-backend/internal/validator"
+)
+
+var (
+	ErrResourceNotFound    = errors.New("resource not found")
+	ErrResourceUnavailable = errors.New("resource unavailable")
+)
+
+type ResourceModel struct {
+	DB *sql.DB
+}
+
+// Resource is something a provider only has so many of, such as chairs or
+// treatment rooms. Services that need it can only be booked while fewer than
+// Capacity appointments are using it.
+type Resource struct {
+	ID         int64     `json:"id"`
+	ProviderID int64     `json:"-"`
+	Name       string    `json:"name"`
+	Capacity   int       `json:"capacity"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+func ValidateResource(v *validator.Validator, r *Resource) {
+	v.Check(strings.TrimSpace(r.Name) != "", "name", "must be provided")
+	v.Check(len(r.Name) <= 100, "name", "must not be more than 100 bytes long")
+	v.Check(r.Capacity > 0, "capacity", "must be greater than zero")
+	v.Check(r.Capacity <= 100, "capacity", "must not be more than 100")
+}
+
+func (m ResourceModel) Insert(r *Resource) error {
+	query := `
+		INSERT INTO resources (provider_id, name, capacity)
+		VALUES ($1, $2, $3)
+		RETURNING id, created_at
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, r.ProviderID, r.Name, r.Capacity).Scan(&r.ID, &r.CreatedAt)
+	if err != nil {
+		if pgErr, ok := err.(*pgconn.PgError); ok && pgErr.Code == "23505" {
+			return ErrDuplicateRecord
+		}
+		return err
+	}
+
+	return nil
+}
+
+func (m ResourceModel) Get(id, providerID int64) (*Resource, error) {
+	query := `
+		SELECT id, provider_id, name, capacity, created_at
+		FROM resources
+		WHERE id = $1 AND provider_id = $2
+	`
+
+	var r Resource
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, id, providerID).Scan(
+		&r.ID,
+		&r.ProviderID,
+		&r.Name,
+		&r.Capacity,
+		&r.CreatedAt,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &r, nil
+}
+
+func (m ResourceModel) GetAllForProvider(providerID int64) ([]*Resource, error) {
+	query := `
+		SELECT id, provider_id, name, capacity, created_at
+		FROM resources
+		WHERE provider_id = $1
+		ORDER BY name
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return m.getAll(ctx, query, providerID)
+}
+
+// GetAllForService returns the resources the service needs.
+func (m ResourceModel) GetAllForService(serviceID, providerID int64) ([]*Resource, error) {
+	query := `
+		SELECT r.id, r.provider_id, r.name, r.capacity, r.created_at
+		FROM resources r
+		INNER JOIN service_resources sr ON sr.resource_id = r.id
+		WHERE sr.service_id = $1 AND sr.provider_id = $2
+		ORDER BY r.name
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return m.getAll(ctx, query, serviceID, providerID)
+}
+
+func (m ResourceModel) getAll(ctx context.Context, query string, args ...any) ([]*Resource, error) {
+	rows, err := m.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	resources := []*Resource{}
+
+	for rows.Next() {
+		var r Resource
+
+		err := rows.Scan(
+			&r.ID,
+			&r.ProviderID,
+			&r.Name,
+			&r.Capacity,
+			&r.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		resources = append(resources, &r)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return resources, nil
+}
+
+// Update changes a resource. Lowering its capacity does not affect
+// appointments that are already booked.
+func (m ResourceModel) Update(r *Resource) error {
+	query := `
+		UPDATE resources
+		SET name = $1, capacity = $2
+		WHERE id = $3 AND provider_id = $4
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, r.Name, r.Capacity, r.ID, r.ProviderID)
+	if err != nil {
+		if pgErr, ok := err.(*pgconn.PgError); ok && pgErr.Code == "23505" {
+			return ErrDuplicateRecord
+		}
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+func (m ResourceModel) Delete(id, providerID int64) error {
+	query := `
+		DELETE FROM resources
+		WHERE id = $1 AND provider_id = $2
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, id, providerID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// SetForService replaces the resources the service needs. Appointments that
+// are already booked keep the resources allocated to them.
+func (m ResourceModel) SetForService(serviceID, providerID int64, resourceIDs []int64) (err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		} else if err != nil {
+			tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	_, err = serviceDuration(ctx, tx, providerID, serviceID)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		DELETE FROM service_resources
+		WHERE service_id = $1 AND provider_id = $2
+	`
+
+	_, err = tx.ExecContext(ctx, query, serviceID, providerID)
+	if err != nil {
+		return err
+	}
+
+	query = `
+		INSERT INTO service_resources (service_id, resource_id, provider_id)
+		VALUES ($1, $2, $3)
+	`
+
+	for _, resourceID := range resourceIDs {
+		_, err = tx.ExecContext(ctx, query, serviceID, resourceID, providerID)
+		if err != nil {
+			if pgErr, ok := err.(*pgconn.PgError); ok && pgErr.Code == "23503" {
+				return ErrResourceNotFound
+			}
+			return err
+		}
+	}
+
+	return nil
+}
+
+// resourceUsage returns, per resource, the blocked time of the appointments
+// using it that overlap the window, leaving out the given appointment. A class
+// session uses its resources once however many clients it has, and the given
+// session is left out too, since joining it takes no further units.
+func resourceUsage(ctx context.Context, q queryer, resourceIDs []int64, window Interval, exceptAppointmentID int64, exceptSession *string) (map[int64][]Interval, error) {
+	query := `
+		SELECT DISTINCT ON (ar.resource_id, COALESCE(a.session_key, a.id::text))
+			ar.resource_id, a.blocked_start, a.blocked_end
+		FROM appointment_resources ar
+		INNER JOIN appointments a ON a.id = ar.appointment_id
+		WHERE ar.resource_id = ANY($1)
+		AND a.status <> 'cancelled'
+		AND a.blocked_start < $3
+		AND a.blocked_end > $2
+		AND a.id <> $4
+		AND (a.session_key IS NULL OR a.session_key IS DISTINCT FROM $5)
+	`
+
+	return staffIntervals(ctx, q, query, resourceIDs, window, exceptAppointmentID, exceptSession)
+}
+
+// peakUsage returns the largest number of the intervals that are in progress
+// at the same moment during iv.
+func peakUsage(intervals []Interval, iv Interval) int {
+	type edge struct {
+		at    time.Time
+		delta int
+	}
+
+	var edges []edge
+
+	for _, other := range intervals {
+		if other.Overlaps(iv) {
+			edges = append(edges, edge{maxTime(other.Start, iv.Start), 1}, edge{minTime(other.End, iv.End), -1})
+		}
+	}
+
+	// An interval ending exactly when another starts is not in progress at
+	// the same time, so ends are counted before starts.
+	slices.SortFunc(edges, func(a, b edge) int {
+		if c := a.at.Compare(b.at); c != 0 {
+			return c
+		}
+		return a.delta - b.delta
+	})
+
+	peak, current := 0, 0
+	for _, e := range edges {
+		current += e.delta
+		peak = max(peak, current)
+	}
+
+	return peak
+}
+
+// allocateResources links the appointment to every resource its service needs
+// for its blocked time, replacing any earlier allocation. The resources are
+// locked in ID order, so concurrent bookings take turns instead of both
+// claiming the last free unit, and ErrResourceUnavailable is returned if any
+// of them is already used to capacity.
+func allocateResources(ctx context.Context, q queryer, a *Appointment) error {
+	_, err := q.ExecContext(ctx, `DELETE FROM appointment_resources WHERE appointment_id = $1`, a.ID)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		SELECT r.id, r.capacity
+		FROM resources r
+		INNER JOIN service_resources sr ON sr.resource_id = r.id
+		WHERE sr.service_id = $1
+		ORDER BY r.id
+		FOR UPDATE OF r
+	`
+
+	rows, err := q.QueryContext(ctx, query, a.ServiceID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var resourceIDs []int64
+	capacity := make(map[int64]int)
+
+	for rows.Next() {
+		var id int64
+		var c int
+
+		err := rows.Scan(&id, &c)
+		if err != nil {
+			return err
+		}
+
+		resourceIDs = append(resourceIDs, id)
+		capacity[id] = c
+	}
+
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	if len(resourceIDs) == 0 {
+		return nil
+	}
+
+	exhausted, err := resourcesExhausted(ctx, q, a, resourceIDs, capacity)
+	if err != nil {
+		return err
+	}
+
+	if exhausted {
+		return ErrResourceUnavailable
+	}
+
+	query = `
+		INSERT INTO appointment_resources (appointment_id, resource_id)
+		VALUES ($1, $2)
+	`
+
+	for _, id := range resourceIDs {
+		_, err = q.ExecContext(ctx, query, a.ID, id)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// resourcesExhausted reports whether any of the resources is already used to
+// capacity at some point during the appointment's blocked time.
+func resourcesExhausted(ctx context.Context, q queryer, a *Appointment, resourceIDs []int64, capacity map[int64]int) (bool, error) {
+	usage, err := resourceUsage(ctx, q, resourceIDs, a.Blocked, a.ID, a.SessionKey)
+	if err != nil {
+		return false, err
+	}
+
+	for _, id := range resourceIDs {
+		if peakUsage(usage[id], a.Blocked) >= capacity[id] {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// resourcesFree reports whether every resource the appointment's service needs
+// has a unit to spare during its blocked time. Unlike allocateResources it
+// neither locks nor allocates anything, so the answer only holds until the
+// slot is actually booked.
+func resourcesFree(ctx context.Context, q queryer, a *Appointment) (bool, error) {
+	query := `
+		SELECT r.id, r.capacity
+		FROM resources r
+		INNER JOIN service_resources sr ON sr.resource_id = r.id
+		WHERE sr.service_id = $1
+	`
+
+	rows, err := q.QueryContext(ctx, query, a.ServiceID)
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+
+	var resourceIDs []int64
+	capacity := make(map[int64]int)
+
+	for rows.Next() {
+		var id int64
+		var c int
+
+		err := rows.Scan(&id, &c)
+		if err != nil {
+			return false, err
+		}
+
+		resourceIDs = append(resourceIDs, id)
+		capacity[id] = c
+	}
+
+	if err = rows.Err(); err != nil {
+		return false, err
+	}
+
+	if len(resourceIDs) == 0 {
+		return true, nil
+	}
+
+	exhausted, err := resourcesExhausted(ctx, q, a, resourceIDs, capacity)
+	if err != nil {
+		return false, err
+	}
+
+	return !exhausted, nil
+}
+
+// resourceDay holds the resources a set of services need, their capacity and
+// the time each of them is already in use on one day.
+type resourceDay struct {
+	needs    map[int64][]int64
+	capacity map[int64]int
+	inUse    map[int64][]Interval
+}
+
+func loadResourceDay(ctx context.Context, q queryer, serviceIDs []int64, day time.Time) (*resourceDay, error) {
+	query := `
+		SELECT sr.service_id, r.id, r.capacity
+		FROM service_resources sr
+		INNER JOIN resources r ON r.id = sr.resource_id
+		WHERE sr.service_id = ANY($1)
+	`
+
+	rows, err := q.QueryContext(ctx, query, serviceIDs)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	r := &resourceDay{
+		needs:    make(map[int64][]int64),
+		capacity: make(map[int64]int),
+	}
+
+	var resourceIDs []int64
+
+	for rows.Next() {
+		var serviceID, resourceID int64
+		var c int
+
+		err := rows.Scan(&serviceID, &resourceID, &c)
+		if err != nil {
+			return nil, err
+		}
+
+		r.needs[serviceID] = append(r.needs[serviceID], resourceID)
+		if _, ok := r.capacity[resourceID]; !ok {
+			resourceIDs = append(resourceIDs, resourceID)
+		}
+		r.capacity[resourceID] = c
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Buffers can reach past midnight on either side.
+	window := Interval{
+		Start: day.Add(-maxServiceBuffer),
+		End:   day.AddDate(0, 0, 1).Add(maxServiceBuffer),
+	}
+
+	r.inUse, err = resourceUsage(ctx, q, resourceIDs, window, 0, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// isFree reports whether every resource the service needs has a unit to spare
+// throughout iv.
+func (r *resourceDay) isFree(serviceID int64, iv Interval) bool {
+	for _, id := range r.needs[serviceID] {
+		if peakUsage(r.inUse[id], iv) >= r.capacity[id] {
+			return false
+		}
+	}
+	return true
+}
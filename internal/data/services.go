@@ -22,17 +22,27 @@ type ServiceModel struct {
 	DB *sql.DB
 }
 
+// maxServiceBuffer caps the prep or cleanup time around a service.
+const maxServiceBuffer = 2 * time.Hour
+
+// maxServiceCapacity caps how many clients a staff member can serve at once
+// in a class.
+const maxServiceCapacity = 50
+
 type Service struct {
-	ID          int64    `json:"id"`
-	ProviderID  int64    `json:"-"`
-	TypeID      int32    `json:"type_id"`
-	Categories  []int32  `json:"categories"`
-	Name        string   `json:"name"`
-	Description string   `json:"description"`
-	Duration    string   `json:"duration"`
-	Price       float64  `json:"price"`
-	Staff       []int64  `json:"staff"`
-	Images      []string `json:"images"`
+	ID           int64    `json:"id"`
+	ProviderID   int64    `json:"-"`
+	TypeID       int32    `json:"type_id"`
+	Categories   []int32  `json:"categories"`
+	Name         string   `json:"name"`
+	Description  string   `json:"description"`
+	Duration     string   `json:"duration"`
+	BufferBefore string   `json:"buffer_before"`
+	BufferAfter  string   `json:"buffer_after"`
+	Capacity     int      `json:"capacity"`
+	Price        float64  `json:"price"`
+	Staff        []int64  `json:"staff"`
+	Images       []string `json:"images"`
 }
 
 func validateDuration(v *validator.Validator, duration string) {
@@ -45,16 +55,49 @@ func validateDuration(v *validator.Validator, duration string) {
 	}
 }
 
+// validateBuffer checks an optional buffer duration. An empty buffer means no
+// buffer at all.
+func validateBuffer(v *validator.Validator, buffer, field string) {
+	if buffer == "" {
+		return
+	}
+	d, err := time.ParseDuration(buffer)
+	if err != nil {
+		v.AddError(field, "must be a valid duration (e.g. '5m', '15m')")
+		return
+	}
+	v.Check(d >= 0, field, "must not be negative")
+	v.Check(d <= maxServiceBuffer, field, "must not be more than 2 hours")
+}
+
 func ValidateService(v *validator.Validator, s *Service) {
+	ValidateServiceDetails(v, s)
+
+	validateIDSlice(v, (s.Categories), "categories")
+	validateIDSlice(v, s.Staff, "staff")
+}
+
+// ValidateServiceDetails checks the fields of a service that can be changed
+// after it has been created.
+func ValidateServiceDetails(v *validator.Validator, s *Service) {
 	v.Check(strings.TrimSpace(s.Name) != "", "name", "must be provided")
 	v.Check(strings.TrimSpace(s.Description) != "", "description", "must be provided")
 	v.Check(s.Price > 0, "price", "must be greater than zero")
 	v.Check(s.TypeID != 0, "type_id", "must be provided")
 
 	validateDuration(v, s.Duration)
+	validateBuffer(v, s.BufferBefore, "buffer_before")
+	validateBuffer(v, s.BufferAfter, "buffer_after")
 
-	validateIDSlice(v, (s.Categories), "categories")
-	validateIDSlice(v, s.Staff, "staff")
+	v.Check(s.Capacity > 0, "capacity", "must be greater than zero")
+	v.Check(s.Capacity <= maxServiceCapacity, "capacity", "must not be more than 50")
+}
+
+// seconds converts a validated duration string to seconds, treating an empty
+// string as zero.
+func seconds(duration string) float64 {
+	d, _ := time.ParseDuration(duration)
+	return d.Seconds()
 }
 
 func validateIDSlice[T ~int | ~int32 | ~int64](v *validator.Validator, values []T, field string) {
@@ -91,8 +134,8 @@ func (m ServiceModel) Insert(s *Service) (err error) {
 	}()
 
 	query := `
-		INSERT INTO services (name, description, duration, price, type_id, provider_id)
-		VALUES ($1, $2, $3, $4, $5, $6)
+		INSERT INTO services (name, description, duration, price, type_id, provider_id, buffer_before, buffer_after, capacity)
+		VALUES ($1, $2, $3, $4, $5, $6, make_interval(secs => $7), make_interval(secs => $8), $9)
 		RETURNING id
 	`
 
@@ -103,6 +146,9 @@ func (m ServiceModel) Insert(s *Service) (err error) {
 		s.Price,
 		s.TypeID,
 		s.ProviderID,
+		seconds(s.BufferBefore),
+		seconds(s.BufferAfter),
+		s.Capacity,
 	}
 
 	err = tx.QueryRowContext(ctx, query, args...).Scan(&s.ID)
@@ -149,10 +195,10 @@ func (m ServiceModel) GetAllForProvider(providerID int64) ([]*Service, error) {
 	defer cancel()
 
 	query := `
-		SELECT s.id, s.name, s.description, s.duration, s.price, s.type_id, s.provider_id
+		SELECT s.id, s.name, s.description, s.duration, s.buffer_before, s.buffer_after, s.capacity, s.price, s.type_id, s.provider_id
 		FROM services s
 		WHERE s.provider_id = $1
-		GROUP BY s.id, s.name, s.description, s.duration, s.price, s.type_id, s.provider_id
+		GROUP BY s.id, s.name, s.description, s.duration, s.buffer_before, s.buffer_after, s.capacity, s.price, s.type_id, s.provider_id
 		ORDER BY s.name
 	`
 
@@ -172,6 +218,9 @@ func (m ServiceModel) GetAllForProvider(providerID int64) ([]*Service, error) {
 			&service.Name,
 			&service.Description,
 			&service.Duration,
+			&service.BufferBefore,
+			&service.BufferAfter,
+			&service.Capacity,
 			&service.Price,
 			&service.TypeID,
 			&service.ProviderID,
@@ -190,6 +239,102 @@ func (m ServiceModel) GetAllForProvider(providerID int64) ([]*Service, error) {
 	return services, nil
 }
 
+// Get returns a service of the provider. Its duration and buffers are written
+// in the same form they are accepted in, so that they can be validated again
+// after a partial update.
+func (m ServiceModel) Get(id, providerID int64) (*Service, error) {
+	query := `
+		SELECT id, name, description,
+			EXTRACT(EPOCH FROM duration)::bigint,
+			EXTRACT(EPOCH FROM buffer_before)::bigint,
+			EXTRACT(EPOCH FROM buffer_after)::bigint,
+			capacity, price, type_id, provider_id
+		FROM services
+		WHERE id = $1 AND provider_id = $2
+	`
+
+	var (
+		service                 Service
+		duration, before, after int64
+	)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, id, providerID).Scan(
+		&service.ID,
+		&service.Name,
+		&service.Description,
+		&duration,
+		&before,
+		&after,
+		&service.Capacity,
+		&service.Price,
+		&service.TypeID,
+		&service.ProviderID,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	service.Duration = (time.Duration(duration) * time.Second).String()
+	service.BufferBefore = (time.Duration(before) * time.Second).String()
+	service.BufferAfter = (time.Duration(after) * time.Second).String()
+
+	return &service, nil
+}
+
+// Update changes a service's details. Appointments already booked keep the
+// duration and buffers they were booked with.
+func (m ServiceModel) Update(s *Service) error {
+	query := `
+		UPDATE services
+		SET name = $1, description = $2, duration = make_interval(secs => $3), price = $4, type_id = $5,
+			buffer_before = make_interval(secs => $6), buffer_after = make_interval(secs => $7), capacity = $8
+		WHERE id = $9 AND provider_id = $10
+	`
+
+	args := []any{
+		s.Name,
+		s.Description,
+		seconds(s.Duration),
+		s.Price,
+		s.TypeID,
+		seconds(s.BufferBefore),
+		seconds(s.BufferAfter),
+		s.Capacity,
+		s.ID,
+		s.ProviderID,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, args...)
+	if err != nil {
+		if pgErr, ok := err.(*pgconn.PgError); ok && pgErr.Code == "23505" {
+			return ErrDuplicateRecord
+		}
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
 func (m ServiceModel) InsertImage(serviceID, providerID int64, imageURL string, isPrimary bool) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
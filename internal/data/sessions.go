@@ -0,0 +1,419 @@
+package data
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+var ErrRefreshTokenReused = errors.New("refresh token reused")
+
+// maxUserAgentLength caps the user agent stored with a session, in bytes.
+const maxUserAgentLength = 512
+
+type SessionModel struct {
+	DB *sql.DB
+}
+
+// Session is a signed in device, backed by an authentication token. Sessions
+// started since refresh tokens were introduced belong to a family that every
+// renewed token joins.
+type Session struct {
+	ID         int64      `json:"id"`
+	UserID     int64      `json:"-"`
+	UserAgent  string     `json:"user_agent"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	Expiry     time.Time  `json:"expiry"`
+	Current    bool       `json:"current"`
+}
+
+// New signs the user in, creating an authentication token along with the
+// refresh token it can be renewed with, and records the user agent they were
+// issued to. Both start a new family.
+func (m SessionModel) New(userID int64, userAgent string, accessTTL, refreshTTL time.Duration) (access, refresh *Token, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		} else if err != nil {
+			tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	var familyID int64
+
+	err = tx.QueryRowContext(ctx, `SELECT nextval('tokens_family_id_seq')`).Scan(&familyID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return insertSessionTokens(ctx, tx, userID, familyID, userAgent, nil, accessTTL, refreshTTL)
+}
+
+// Refresh exchanges an unused refresh token for a new authentication token and
+// refresh token in the same family, replacing the family's previous
+// authentication token. A refresh token that has been used before has most
+// likely been stolen, so presenting it again revokes the whole family and
+// returns ErrRefreshTokenReused.
+func (m SessionModel) Refresh(tokenPlaintext, userAgent string, accessTTL, refreshTTL time.Duration) (*Token, *Token, error) {
+	access, refresh, reused, err := m.rotate(tokenPlaintext, userAgent, accessTTL, refreshTTL)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if reused {
+		return nil, nil, ErrRefreshTokenReused
+	}
+
+	return access, refresh, nil
+}
+
+// rotate does the work of Refresh. The revocation of a family is committed
+// like any other change, so it is reported through reused rather than as an
+// error.
+func (m SessionModel) rotate(tokenPlaintext, userAgent string, accessTTL, refreshTTL time.Duration) (access, refresh *Token, reused bool, err error) {
+	tokenHash := sha256.Sum256([]byte(tokenPlaintext))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, nil, false, err
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		} else if err != nil {
+			tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	query := `
+		SELECT id, user_id, family_id, used_at
+		FROM tokens
+		WHERE hash = $1
+		AND scope = $2
+		AND expiry > NOW()
+		FOR UPDATE
+	`
+
+	var (
+		id       int64
+		userID   int64
+		familyID int64
+		usedAt   *time.Time
+	)
+
+	err = tx.QueryRowContext(ctx, query, tokenHash[:], ScopeRefresh).Scan(&id, &userID, &familyID, &usedAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, nil, false, ErrRecordNotFound
+		default:
+			return nil, nil, false, err
+		}
+	}
+
+	if usedAt != nil {
+		_, err = tx.ExecContext(ctx, `DELETE FROM tokens WHERE family_id = $1`, familyID)
+		if err != nil {
+			return nil, nil, false, err
+		}
+		return nil, nil, true, nil
+	}
+
+	_, err = tx.ExecContext(ctx, `UPDATE tokens SET used_at = NOW() WHERE id = $1`, id)
+	if err != nil {
+		return nil, nil, false, err
+	}
+
+	// The session keeps the time it was first created at, even though its
+	// authentication token is replaced.
+	query = `
+		WITH replaced AS (
+			DELETE FROM tokens
+			WHERE family_id = $1 AND scope = $2
+			RETURNING created_at
+		)
+		SELECT MIN(created_at) FROM replaced
+	`
+
+	var createdAt *time.Time
+
+	err = tx.QueryRowContext(ctx, query, familyID, ScopeAuthentication).Scan(&createdAt)
+	if err != nil {
+		return nil, nil, false, err
+	}
+
+	access, refresh, err = insertSessionTokens(ctx, tx, userID, familyID, userAgent, createdAt, accessTTL, refreshTTL)
+	if err != nil {
+		return nil, nil, false, err
+	}
+
+	return access, refresh, false, nil
+}
+
+// cleanUserAgent makes a user agent safe to store as text. Headers may hold
+// bytes that are not valid UTF-8, and NUL is not allowed in a Postgres string,
+// so both are dropped before the value is cut to maxUserAgentLength bytes
+// without splitting a character.
+func cleanUserAgent(userAgent string) string {
+	userAgent = strings.ToValidUTF8(userAgent, "")
+	userAgent = strings.ReplaceAll(userAgent, "\x00", "")
+
+	if len(userAgent) <= maxUserAgentLength {
+		return userAgent
+	}
+
+	cut := maxUserAgentLength
+	for cut > 0 && !utf8.RuneStart(userAgent[cut]) {
+		cut--
+	}
+
+	return userAgent[:cut]
+}
+
+// insertSessionTokens creates an authentication token and a refresh token in
+// the family. The authentication token is dated createdAt, or now if it is nil.
+func insertSessionTokens(ctx context.Context, q queryer, userID, familyID int64, userAgent string, createdAt *time.Time, accessTTL, refreshTTL time.Duration) (*Token, *Token, error) {
+	access, err := generateToken(userID, accessTTL, ScopeAuthentication)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	refresh, err := generateToken(userID, refreshTTL, ScopeRefresh)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	userAgent = cleanUserAgent(userAgent)
+
+	query := `
+		INSERT INTO tokens (hash, user_id, expiry, scope, user_agent, family_id, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, COALESCE($7, NOW())), ($8, $2, $9, $10, $5, $6, NOW())
+	`
+
+	args := []any{
+		access.Hash,
+		userID,
+		access.Expiry,
+		access.Scope,
+		userAgent,
+		familyID,
+		createdAt,
+		refresh.Hash,
+		refresh.Expiry,
+		refresh.Scope,
+	}
+
+	_, err = q.ExecContext(ctx, query, args...)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return access, refresh, nil
+}
+
+// GetForToken returns the session of an unexpired authentication token along
+// with the user it belongs to, in a single query so that authenticating a
+// request costs no more than before.
+func (m SessionModel) GetForToken(tokenPlaintext string) (*Session, *User, error) {
+	tokenHash := sha256.Sum256([]byte(tokenPlaintext))
+
+	query := `
+		SELECT t.id, t.user_agent, t.created_at, t.last_used_at, t.expiry,
+			u.id, u.first_name, u.last_name, u.email, u.phone_number, u.role, u.password_hash, u.activated
+		FROM tokens t
+		INNER JOIN users u ON u.id = t.user_id
+		WHERE t.hash = $1
+		AND t.scope = $2
+		AND t.expiry > NOW()
+	`
+
+	var (
+		s    Session
+		user User
+	)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, tokenHash[:], ScopeAuthentication).Scan(
+		&s.ID,
+		&s.UserAgent,
+		&s.CreatedAt,
+		&s.LastUsedAt,
+		&s.Expiry,
+		&user.ID,
+		&user.FirstName,
+		&user.LastName,
+		&user.Email,
+		&user.PhoneNumber,
+		&user.Role,
+		&user.Password.hash,
+		&user.Activated,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, nil, ErrRecordNotFound
+		default:
+			return nil, nil, err
+		}
+	}
+
+	s.UserID = user.ID
+	s.Current = true
+
+	return &s, &user, nil
+}
+
+// GetAllForUser returns the user's unexpired sessions, most recently used
+// first.
+func (m SessionModel) GetAllForUser(userID int64) ([]*Session, error) {
+	query := `
+		SELECT id, user_id, user_agent, created_at, last_used_at, expiry
+		FROM tokens
+		WHERE user_id = $1
+		AND scope = $2
+		AND expiry > NOW()
+		ORDER BY COALESCE(last_used_at, created_at) DESC, id DESC
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, userID, ScopeAuthentication)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := make([]*Session, 0)
+
+	for rows.Next() {
+		var s Session
+
+		err := rows.Scan(
+			&s.ID,
+			&s.UserID,
+			&s.UserAgent,
+			&s.CreatedAt,
+			&s.LastUsedAt,
+			&s.Expiry,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		sessions = append(sessions, &s)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return sessions, nil
+}
+
+// Touch records that the session has just been used.
+func (m SessionModel) Touch(id int64) error {
+	query := `
+		UPDATE tokens
+		SET last_used_at = NOW()
+		WHERE id = $1
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, id)
+	return err
+}
+
+// Delete revokes one of the user's sessions, along with the refresh tokens
+// that could renew it.
+func (m SessionModel) Delete(id, userID int64) error {
+	query := `
+		WITH session AS (
+			SELECT id, family_id
+			FROM tokens
+			WHERE id = $1 AND user_id = $2 AND scope = $3
+		)
+		DELETE FROM tokens t
+		USING session s
+		WHERE t.id = s.id OR t.family_id = s.family_id
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, id, userID, ScopeAuthentication)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// DeleteAllExcept revokes every session of the user other than the one given,
+// refresh tokens included, and returns how many sessions were revoked. Only
+// the given token and the refresh tokens of its family, if it has one, are
+// kept; every other token of the user goes, whatever family it belongs to.
+func (m SessionModel) DeleteAllExcept(userID, id int64) (int64, error) {
+	query := `
+		WITH revoked AS (
+			DELETE FROM tokens
+			WHERE user_id = $1
+			AND scope IN ($2, $3)
+			AND id <> $4
+			AND NOT (
+				scope = $3
+				AND COALESCE(family_id = (SELECT family_id FROM tokens WHERE id = $4), false)
+			)
+			RETURNING scope
+		)
+		SELECT count(*) FROM revoked WHERE scope = $2
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var revoked int64
+
+	err := m.DB.QueryRowContext(ctx, query, userID, ScopeAuthentication, ScopeRefresh, id).Scan(&revoked)
+	if err != nil {
+		return 0, err
+	}
+
+	return revoked, nil
+}
@@ -0,0 +1,41 @@
+package data
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestCleanUserAgent(t *testing.T) {
+	tests := []struct {
+		name      string
+		userAgent string
+		want      string
+	}{
+		{"short", "Mozilla/5.0", "Mozilla/5.0"},
+		{"invalid utf-8", "Mozilla/5.0 \xff\xfe(X11)", "Mozilla/5.0 (X11)"},
+		{"nul byte", "Mozilla/5.0\x00", "Mozilla/5.0"},
+		{"exactly the limit", strings.Repeat("a", maxUserAgentLength), strings.Repeat("a", maxUserAgentLength)},
+		{"too long", strings.Repeat("a", maxUserAgentLength+10), strings.Repeat("a", maxUserAgentLength)},
+		{
+			// The three-byte character straddles the limit, so it is
+			// dropped whole.
+			"too long with a character across the limit",
+			strings.Repeat("a", maxUserAgentLength-1) + "€",
+			strings.Repeat("a", maxUserAgentLength-1),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := cleanUserAgent(tt.userAgent)
+
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+			if !utf8.ValidString(got) {
+				t.Errorf("got invalid UTF-8 %q", got)
+			}
+		})
+	}
+}
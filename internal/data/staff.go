@@ -115,6 +115,39 @@ func (m StaffModel) Insert(s *Staff) error {
 	return nil
 }
 
+func (m StaffModel) Get(id int64) (*Staff, error) {
+	query := `
+		SELECT id, provider_id, name, phone, email, profile_picture, is_owner
+		FROM staff
+		WHERE id = $1
+	`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var s Staff
+
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(
+		&s.ID,
+		&s.ProviderID,
+		&s.Name,
+		&s.Phone,
+		&s.Email,
+		&s.ProfilePicture,
+		&s.IsOwner,
+	)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &s, nil
+}
+
 func (m StaffModel) GetAllForProvider(providerID int64) ([]*Staff, error) {
 	query := `
 		SELECT id, name, phone, email, profile_picture, is_owner
@@ -156,3 +189,26 @@ func (m StaffModel) GetAllForProvider(providerID int64) ([]*Staff, error) {
 
 	return staffList, nil
 }
+
+// IsOwner reports whether the staff member with the given email is marked as
+// the owner of the provider.
+func (m StaffModel) IsOwner(providerID int64, email string) (bool, error) {
+	query := `
+		SELECT EXISTS (
+			SELECT 1
+			FROM staff
+			WHERE provider_id = $1 AND email = $2 AND is_owner
+		)
+	`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var isOwner bool
+
+	err := m.DB.QueryRowContext(ctx, query, providerID, email).Scan(&isOwner)
+	if err != nil {
+		return false, err
+	}
+
+	return isOwner, nil
+}
@@ -0,0 +1,259 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/tormgibbs/snapluks-backend/internal/validator"
+)
+
+type StaffScheduleModel struct {
+	DB *sql.DB
+}
+
+type StaffSchedule struct {
+	ID         int64      `json:"id"`
+	StaffID    int64      `json:"staff_id"`
+	ProviderID int64      `json:"-"`
+	DayOfWeek  int        `json:"day_of_week"`
+	StartTime  *LocalTime `json:"start_time"`
+	EndTime    *LocalTime `json:"end_time"`
+}
+
+// ValidateStaffSchedule checks a single shift. The staff member's other shifts
+// are passed in so that overlaps on the same day are reported.
+func ValidateStaffSchedule(v *validator.Validator, s *StaffSchedule, others []*StaffSchedule) {
+	v.Check(s.StaffID > 0, "staff_id", "must be provided and greater than zero")
+	v.Check(s.DayOfWeek >= 0 && s.DayOfWeek <= 6, "day_of_week", "must be between 0 (Sunday) and 6 (Saturday)")
+
+	v.Check(s.StartTime != nil, "start_time", "must be provided")
+	v.Check(s.EndTime != nil, "end_time", "must be provided")
+
+	if s.StartTime != nil && s.EndTime != nil {
+		v.Check(s.StartTime.Before(*s.EndTime), "time", "start_time must be before end_time")
+	}
+
+	for _, o := range others {
+		if o == s || (o.ID != 0 && o.ID == s.ID) || o.DayOfWeek != s.DayOfWeek {
+			continue
+		}
+
+		if s.overlaps(o) {
+			v.AddError("time", "must not overlap another shift on the same day")
+		}
+	}
+}
+
+func (s *StaffSchedule) overlaps(o *StaffSchedule) bool {
+	if s.StartTime == nil || s.EndTime == nil || o.StartTime == nil || o.EndTime == nil {
+		return false
+	}
+	return s.StartTime.Before(*o.EndTime) && o.StartTime.Before(*s.EndTime)
+}
+
+// isShiftOverlap reports whether err was raised by the constraint that keeps a
+// staff member's shifts on the same day apart.
+func isShiftOverlap(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23P01" && pgErr.ConstraintName == "staff_schedules_no_overlap"
+}
+
+// withinBusinessHours returns ErrOutsideBusinessHours unless the schedule lies
+// entirely within one of the provider's opening ranges for that day.
+func (s *StaffSchedule) withinBusinessHours(ctx context.Context, q queryer) error {
+	ranges, err := weeklyHours(ctx, q, s.ProviderID, time.Weekday(s.DayOfWeek))
+	if err != nil {
+		return err
+	}
+
+	for _, tr := range ranges {
+		if !s.StartTime.Before(tr.Start) && !tr.End.Before(*s.EndTime) {
+			return nil
+		}
+	}
+
+	return ErrOutsideBusinessHours
+}
+
+func (m StaffScheduleModel) Insert(s *StaffSchedule) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := s.withinBusinessHours(ctx, m.DB)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO staff_schedules (staff_id, provider_id, day_of_week, start_time, end_time)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id
+	`
+
+	args := []any{
+		s.StaffID,
+		s.ProviderID,
+		s.DayOfWeek,
+		s.StartTime,
+		s.EndTime,
+	}
+
+	err = m.DB.QueryRowContext(ctx, query, args...).Scan(&s.ID)
+	if err != nil {
+		if isShiftOverlap(err) {
+			return ErrOverlappingHours
+		}
+		return err
+	}
+
+	return nil
+}
+
+func (m StaffScheduleModel) Get(id, staffID int64) (*StaffSchedule, error) {
+	query := `
+		SELECT id, staff_id, provider_id, day_of_week, start_time, end_time
+		FROM staff_schedules
+		WHERE id = $1 AND staff_id = $2
+	`
+
+	var s StaffSchedule
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, id, staffID).Scan(
+		&s.ID,
+		&s.StaffID,
+		&s.ProviderID,
+		&s.DayOfWeek,
+		&s.StartTime,
+		&s.EndTime,
+	)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &s, nil
+}
+
+func (m StaffScheduleModel) GetAllForStaff(staffID int64) ([]*StaffSchedule, error) {
+	query := `
+		SELECT id, staff_id, provider_id, day_of_week, start_time, end_time
+		FROM staff_schedules
+		WHERE staff_id = $1
+		ORDER BY day_of_week, start_time
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, staffID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	schedules := make([]*StaffSchedule, 0)
+
+	for rows.Next() {
+		var s StaffSchedule
+		err := rows.Scan(
+			&s.ID,
+			&s.StaffID,
+			&s.ProviderID,
+			&s.DayOfWeek,
+			&s.StartTime,
+			&s.EndTime,
+		)
+		if err != nil {
+			return nil, err
+		}
+		schedules = append(schedules, &s)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return schedules, nil
+}
+
+func (m StaffScheduleModel) Update(s *StaffSchedule) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := s.withinBusinessHours(ctx, m.DB)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		UPDATE staff_schedules
+		SET day_of_week = $1,
+			start_time = $2,
+			end_time = $3
+		WHERE id = $4 AND staff_id = $5
+	`
+
+	args := []any{
+		s.DayOfWeek,
+		s.StartTime,
+		s.EndTime,
+		s.ID,
+		s.StaffID,
+	}
+
+	result, err := m.DB.ExecContext(ctx, query, args...)
+	if err != nil {
+		if isShiftOverlap(err) {
+			return ErrOverlappingHours
+		}
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+func (m StaffScheduleModel) Delete(id, staffID int64) error {
+	query := `
+		DELETE FROM staff_schedules
+		WHERE id = $1 AND staff_id = $2
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, id, staffID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,200 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/tormgibbs/snapluks-backend/internal/validator"
+)
+
+type StaffTimeOffModel struct {
+	DB *sql.DB
+}
+
+// StaffTimeOff is a dated block, such as a holiday or a break, during which a
+// staff member cannot be booked.
+type StaffTimeOff struct {
+	ID         int64     `json:"id"`
+	StaffID    int64     `json:"staff_id"`
+	ProviderID int64     `json:"-"`
+	StartTime  time.Time `json:"start_time"`
+	EndTime    time.Time `json:"end_time"`
+	Reason     *string   `json:"reason,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+func ValidateStaffTimeOff(v *validator.Validator, t *StaffTimeOff) {
+	v.Check(t.StaffID > 0, "staff_id", "must be provided and greater than zero")
+
+	v.Check(!t.StartTime.IsZero(), "start_time", "must be provided")
+	v.Check(!t.EndTime.IsZero(), "end_time", "must be provided")
+	v.Check(t.StartTime.Before(t.EndTime), "time", "start_time must be before end_time")
+
+	if t.Reason != nil {
+		v.Check(len(*t.Reason) <= 500, "reason", "must not be more than 500 bytes long")
+	}
+}
+
+func (m StaffTimeOffModel) Insert(t *StaffTimeOff) error {
+	query := `
+		INSERT INTO staff_time_off (staff_id, provider_id, start_time, end_time, reason)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at
+	`
+
+	args := []any{
+		t.StaffID,
+		t.ProviderID,
+		t.StartTime,
+		t.EndTime,
+		t.Reason,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return m.DB.QueryRowContext(ctx, query, args...).Scan(&t.ID, &t.CreatedAt)
+}
+
+func (m StaffTimeOffModel) Get(id, staffID int64) (*StaffTimeOff, error) {
+	query := `
+		SELECT id, staff_id, provider_id, start_time, end_time, reason, created_at
+		FROM staff_time_off
+		WHERE id = $1 AND staff_id = $2
+	`
+
+	var t StaffTimeOff
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, id, staffID).Scan(
+		&t.ID,
+		&t.StaffID,
+		&t.ProviderID,
+		&t.StartTime,
+		&t.EndTime,
+		&t.Reason,
+		&t.CreatedAt,
+	)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &t, nil
+}
+
+// GetAllForStaff returns the staff member's time off that has not yet ended.
+func (m StaffTimeOffModel) GetAllForStaff(staffID int64) ([]*StaffTimeOff, error) {
+	query := `
+		SELECT id, staff_id, provider_id, start_time, end_time, reason, created_at
+		FROM staff_time_off
+		WHERE staff_id = $1 AND end_time > NOW()
+		ORDER BY start_time
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, staffID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	timeOff := make([]*StaffTimeOff, 0)
+
+	for rows.Next() {
+		var t StaffTimeOff
+		err := rows.Scan(
+			&t.ID,
+			&t.StaffID,
+			&t.ProviderID,
+			&t.StartTime,
+			&t.EndTime,
+			&t.Reason,
+			&t.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		timeOff = append(timeOff, &t)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return timeOff, nil
+}
+
+func (m StaffTimeOffModel) Update(t *StaffTimeOff) error {
+	query := `
+		UPDATE staff_time_off
+		SET start_time = $1,
+			end_time = $2,
+			reason = $3
+		WHERE id = $4 AND staff_id = $5
+	`
+
+	args := []any{
+		t.StartTime,
+		t.EndTime,
+		t.Reason,
+		t.ID,
+		t.StaffID,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+func (m StaffTimeOffModel) Delete(id, staffID int64) error {
+	query := `
+		DELETE FROM staff_time_off
+		WHERE id = $1 AND staff_id = $2
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, id, staffID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
@@ -15,6 +15,9 @@ const (
 	ScopeActivation     = "activation"
 	ScopeAuthentication = "authentication"
 	ScopePasswordReset  = "password-reset"
+	ScopeWaitlistClaim  = "waitlist-claim"
+	ScopeCalendarFeed   = "calendar-feed"
+	ScopeRefresh        = "refresh"
 )
 
 // Token struct represents the structure of a token.
@@ -40,7 +43,7 @@ func generateToken(userID int64, ttl time.Duration, scope string) (*Token, error
 	var randomBytes []byte
 
 	switch scope {
-	case ScopeAuthentication:
+	case ScopeAuthentication, ScopeWaitlistClaim, ScopeCalendarFeed, ScopeRefresh:
 		randomBytes = make([]byte, 16)
 	default:
 		randomBytes = make([]byte, 4)
@@ -53,7 +56,7 @@ func generateToken(userID int64, ttl time.Duration, scope string) (*Token, error
 
 	encoded := base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(randomBytes)
 
-	if scope == ScopeAuthentication {
+	if scope == ScopeAuthentication || scope == ScopeWaitlistClaim || scope == ScopeCalendarFeed || scope == ScopeRefresh {
 		token.Plaintext = encoded
 	} else {
 		token.Plaintext = encoded[:6]
@@ -70,7 +73,7 @@ func ValidateTokenPlaintext(v *validator.Validator, tokenPlaintext string, scope
 	v.Check(tokenPlaintext != "", "token", "must be provided")
 
 	switch scope {
-	case ScopeAuthentication:
+	case ScopeAuthentication, ScopeWaitlistClaim, ScopeCalendarFeed, ScopeRefresh:
 		v.Check(len(tokenPlaintext) == 26, "token", "must be 26 characters long")
 	default:
 		v.Check(len(tokenPlaintext) == 6, "token", "must be 6 characters long")
@@ -0,0 +1,438 @@
+package data
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/tormgibbs/snapluks-backend/internal/validator"
+)
+
+// maxWaitlistDays caps how many days a single waitlist entry may cover.
+const maxWaitlistDays = 31
+
+// maxOfferCandidates caps how many waiting entries are tried for one freed
+// slot, so that checking them fits within the timeout of a single transaction.
+const maxOfferCandidates = 20
+
+var ErrInvalidClaimToken = errors.New("invalid claim token")
+
+const (
+	WaitlistWaiting = "waiting"
+	WaitlistBooked  = "booked"
+)
+
+type WaitlistModel struct {
+	DB *sql.DB
+}
+
+// WaitlistEntry is a client waiting for a slot with a provider's service on
+// any date from StartDate to EndDate inclusive, optionally with a particular
+// staff member.
+type WaitlistEntry struct {
+	ID         int64     `json:"id"`
+	ProviderID int64     `json:"provider_id"`
+	ServiceID  int64     `json:"service_id"`
+	StaffID    *int64    `json:"staff_id,omitempty"`
+	ClientID   int64     `json:"client_id"`
+	StartDate  Date      `json:"start_date"`
+	EndDate    Date      `json:"end_date"`
+	Status     string    `json:"status"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// WaitlistOffer is a freed slot held for the client of a waitlist entry until
+// the offer expires or the client claims it with the emailed token.
+type WaitlistOffer struct {
+	ID          int64     `json:"id"`
+	EntryID     int64     `json:"entry_id"`
+	StaffID     int64     `json:"staff_id"`
+	StartTime   time.Time `json:"start_time"`
+	EndTime     time.Time `json:"end_time"`
+	Expiry      time.Time `json:"expiry"`
+	Token       *Token    `json:"-"`
+	ClientEmail string    `json:"-"`
+}
+
+func ValidateWaitlistEntry(v *validator.Validator, e *WaitlistEntry) {
+	v.Check(e.ProviderID > 0, "provider_id", "must be provided and greater than zero")
+	v.Check(e.ServiceID > 0, "service_id", "must be provided and greater than zero")
+
+	if e.StaffID != nil {
+		v.Check(*e.StaffID > 0, "staff_id", "must be greater than zero")
+	}
+
+	v.Check(!e.StartDate.IsZero(), "start_date", "must be provided")
+	v.Check(!e.EndDate.IsZero(), "end_date", "must be provided")
+	v.Check(!e.EndDate.Before(e.StartDate.Time), "end_date", "must not be before start_date")
+	v.Check(!e.EndDate.Before(DateOf(time.Now()).Time), "end_date", "must not be in the past")
+	v.Check(e.EndDate.Sub(e.StartDate.Time) < maxWaitlistDays*24*time.Hour, "end_date", "must not be more than 31 days after start_date")
+}
+
+// Insert adds the client to the waitlist. The service must be offered by the
+// provider and, if a staff member is given, by that staff member.
+func (m WaitlistModel) Insert(e *WaitlistEntry) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := serviceDuration(ctx, m.DB, e.ProviderID, e.ServiceID)
+	if err != nil {
+		return err
+	}
+
+	if e.StaffID != nil {
+		_, err = offeringStaff(ctx, m.DB, e.ProviderID, e.ServiceID, *e.StaffID)
+		if err != nil {
+			return err
+		}
+	}
+
+	query := `
+		INSERT INTO waitlist_entries (provider_id, service_id, staff_id, client_id, start_date, end_date)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, status, created_at
+	`
+
+	args := []any{
+		e.ProviderID,
+		e.ServiceID,
+		e.StaffID,
+		e.ClientID,
+		e.StartDate,
+		e.EndDate,
+	}
+
+	return m.DB.QueryRowContext(ctx, query, args...).Scan(&e.ID, &e.Status, &e.CreatedAt)
+}
+
+func (m WaitlistModel) GetAllForClient(clientID int64) ([]*WaitlistEntry, error) {
+	query := `
+		SELECT id, provider_id, service_id, staff_id, client_id, start_date, end_date, status, created_at
+		FROM waitlist_entries
+		WHERE client_id = $1
+		ORDER BY created_at DESC, id DESC
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, clientID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []*WaitlistEntry{}
+
+	for rows.Next() {
+		var e WaitlistEntry
+
+		err := rows.Scan(
+			&e.ID,
+			&e.ProviderID,
+			&e.ServiceID,
+			&e.StaffID,
+			&e.ClientID,
+			&e.StartDate,
+			&e.EndDate,
+			&e.Status,
+			&e.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		entries = append(entries, &e)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+func (m WaitlistModel) Delete(id, clientID int64) error {
+	query := `
+		DELETE FROM waitlist_entries
+		WHERE id = $1 AND client_id = $2
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, id, clientID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// OfferSlot offers the slot freed by a cancelled appointment to the earliest
+// waiting entry it suits: same provider, a date within the entry's range, a
+// service the staff member offers that fits in the freed time and can be
+// booked there, resources included, and no other offer pending for the entry.
+// Up to maxOfferCandidates entries are tried in the order they joined until
+// one is found, and the slot is offered to that one only. It is held for that
+// client until the offer expires, which is after ttl or when the slot starts,
+// whichever comes first. It returns nil if nobody waiting can take the slot or
+// it has been taken again in the meantime.
+func (m WaitlistModel) OfferSlot(appointmentID int64, ttl time.Duration) (offer *WaitlistOffer, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		} else if err != nil {
+			tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	query := `
+		SELECT e.id, e.provider_id, e.service_id, e.client_id, u.email, a.staff_id, a.start_time
+		FROM appointments a
+		INNER JOIN providers p ON p.id = a.provider_id
+		INNER JOIN waitlist_entries e ON e.provider_id = a.provider_id
+		INNER JOIN services s ON s.id = e.service_id
+		INNER JOIN staff_services ss ON ss.service_id = e.service_id AND ss.staff_id = a.staff_id
+		INNER JOIN users u ON u.id = e.client_id
+		WHERE a.id = $1
+		AND a.status = 'cancelled'
+		AND a.start_time > NOW()
+		AND e.status = 'waiting'
+		AND e.client_id <> a.client_id
+		AND (e.staff_id IS NULL OR e.staff_id = a.staff_id)
+		AND (a.start_time AT TIME ZONE p.timezone)::date BETWEEN e.start_date AND e.end_date
+		AND s.duration <= a.end_time - a.start_time
+		AND NOT EXISTS (
+			SELECT 1
+			FROM waitlist_offers o
+			WHERE o.entry_id = e.id AND o.claimed_at IS NULL AND o.expiry > NOW()
+		)
+		ORDER BY e.created_at, e.id
+		LIMIT $2
+		FOR UPDATE OF e SKIP LOCKED
+	`
+
+	type candidate struct {
+		entryID int64
+		email   string
+		a       Appointment
+	}
+
+	rows, err := tx.QueryContext(ctx, query, appointmentID, maxOfferCandidates)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var candidates []candidate
+
+	for rows.Next() {
+		var c candidate
+
+		err = rows.Scan(
+			&c.entryID,
+			&c.a.ProviderID,
+			&c.a.ServiceID,
+			&c.a.ClientID,
+			&c.email,
+			&c.a.StaffID,
+			&c.a.StartTime,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		candidates = append(candidates, c)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, c := range candidates {
+		a := c.a
+
+		ok, err := offerable(ctx, tx, &a)
+		if err != nil {
+			return nil, err
+		}
+
+		if ok {
+			return insertOffer(ctx, tx, c.entryID, c.email, &a, ttl)
+		}
+	}
+
+	return nil, nil
+}
+
+// offerable reports whether the freed slot can still be booked for the
+// waiting client's service. The slot may have been rebooked, or the provider's
+// hours changed, since the appointment was cancelled, and the service may need
+// resources that are in use at the time.
+func offerable(ctx context.Context, q queryer, a *Appointment) (bool, error) {
+	err := checkBookable(ctx, q, a)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrOutsideBusinessHours),
+			errors.Is(err, ErrStaffUnavailable),
+			errors.Is(err, ErrSlotUnavailable),
+			errors.Is(err, ErrSessionFull):
+			return false, nil
+		default:
+			return false, err
+		}
+	}
+
+	booked, err := bookedIntervals(ctx, q, []int64{a.StaffID}, a.Blocked)
+	if err != nil {
+		return false, err
+	}
+
+	if len(booked[a.StaffID]) > 0 {
+		return false, nil
+	}
+
+	return resourcesFree(ctx, q, a)
+}
+
+// insertOffer holds the slot for the waiting entry and creates the token the
+// client claims it with.
+func insertOffer(ctx context.Context, q queryer, entryID int64, email string, a *Appointment, ttl time.Duration) (*WaitlistOffer, error) {
+	token, err := generateToken(a.ClientID, ttl, ScopeWaitlistClaim)
+	if err != nil {
+		return nil, err
+	}
+
+	o := WaitlistOffer{
+		EntryID:     entryID,
+		StaffID:     a.StaffID,
+		StartTime:   a.StartTime,
+		EndTime:     a.EndTime,
+		Expiry:      minTime(token.Expiry, a.StartTime),
+		Token:       token,
+		ClientEmail: email,
+	}
+	o.Token.Expiry = o.Expiry
+
+	query := `
+		INSERT INTO waitlist_offers (entry_id, staff_id, start_time, end_time, token_hash, expiry)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id
+	`
+
+	args := []any{
+		o.EntryID,
+		o.StaffID,
+		o.StartTime,
+		o.EndTime,
+		o.Token.Hash,
+		o.Expiry,
+	}
+
+	err = q.QueryRowContext(ctx, query, args...).Scan(&o.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &o, nil
+}
+
+// Claim books the slot held by the offer matching the token for the client it
+// was offered to, and takes the entry off the waitlist.
+func (m WaitlistModel) Claim(tokenPlaintext string, clientID int64) (a *Appointment, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		} else if err != nil {
+			tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	tokenHash := sha256.Sum256([]byte(tokenPlaintext))
+
+	query := `
+		SELECT o.id, o.entry_id, o.staff_id, o.start_time, e.provider_id, e.service_id, e.client_id
+		FROM waitlist_offers o
+		INNER JOIN waitlist_entries e ON e.id = o.entry_id
+		WHERE o.token_hash = $1
+		AND o.claimed_at IS NULL
+		AND o.expiry > NOW()
+		FOR UPDATE OF o, e
+	`
+
+	var offerID, entryID int64
+	a = &Appointment{}
+
+	err = tx.QueryRowContext(ctx, query, tokenHash[:]).Scan(
+		&offerID,
+		&entryID,
+		&a.StaffID,
+		&a.StartTime,
+		&a.ProviderID,
+		&a.ServiceID,
+		&a.ClientID,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrInvalidClaimToken
+		default:
+			return nil, err
+		}
+	}
+
+	if a.ClientID != clientID {
+		return nil, ErrInvalidClaimToken
+	}
+
+	err = insertAppointment(ctx, tx, a)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.ExecContext(ctx, `UPDATE waitlist_offers SET claimed_at = NOW() WHERE id = $1`, offerID)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.ExecContext(ctx, `UPDATE waitlist_entries SET status = $1 WHERE id = $2`, WaitlistBooked, entryID)
+	if err != nil {
+		return nil, err
+	}
+
+	return a, nil
+}
@@ -0,0 +1,464 @@
+package data
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base32"
+	"errors"
+	"math"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/tormgibbs/snapluks-backend/internal/validator"
+)
+
+var ErrClientNotFound = errors.New("client not found")
+
+const (
+	WalkInWaiting = "waiting"
+	WalkInServing = "serving"
+	WalkInServed  = "served"
+	WalkInLeft    = "left"
+)
+
+var walkInTransitions = map[string][]string{
+	WalkInWaiting: {WalkInServing, WalkInLeft},
+	WalkInServing: {WalkInServed},
+}
+
+type WalkInModel struct {
+	DB *sql.DB
+}
+
+// WalkIn is a client waiting in a provider's live queue. Registered clients
+// are linked by ClientID, anyone else is identified by name and phone number.
+// Position and the estimates are only set while the walk-in is waiting.
+type WalkIn struct {
+	ID                   int64      `json:"id"`
+	ProviderID           int64      `json:"provider_id"`
+	ServiceID            int64      `json:"service_id"`
+	StaffID              *int64     `json:"staff_id,omitempty"`
+	ClientID             *int64     `json:"client_id,omitempty"`
+	Name                 *string    `json:"name,omitempty"`
+	PhoneNumber          *string    `json:"phone_number,omitempty"`
+	Ticket               string     `json:"ticket"`
+	Status               string     `json:"status"`
+	CreatedAt            time.Time  `json:"created_at"`
+	StartedAt            *time.Time `json:"started_at,omitempty"`
+	FinishedAt           *time.Time `json:"finished_at,omitempty"`
+	Position             int        `json:"position,omitempty"`
+	EstimatedStart       *time.Time `json:"estimated_start,omitempty"`
+	EstimatedWaitMinutes *int       `json:"estimated_wait_minutes,omitempty"`
+}
+
+func ValidateWalkIn(v *validator.Validator, w *WalkIn) {
+	v.Check(w.ServiceID > 0, "service_id", "must be provided and greater than zero")
+
+	if w.StaffID != nil {
+		v.Check(*w.StaffID > 0, "staff_id", "must be greater than zero")
+	}
+
+	if w.ClientID != nil {
+		v.Check(*w.ClientID > 0, "client_id", "must be greater than zero")
+	} else {
+		v.Check(w.Name != nil && *w.Name != "", "name", "must be provided for walk-ins without an account")
+		v.Check(w.PhoneNumber != nil && *w.PhoneNumber != "", "phone_number", "must be provided for walk-ins without an account")
+	}
+
+	if w.Name != nil {
+		v.Check(len(*w.Name) <= 100, "name", "must not be more than 100 bytes long")
+	}
+
+	if w.PhoneNumber != nil && *w.PhoneNumber != "" {
+		v.Check(validator.Matches(*w.PhoneNumber, validator.PhoneRX), "phone_number", "must be a valid phone number")
+	}
+}
+
+// CanWalkInTransition reports whether a walk-in in status from may move to next.
+func CanWalkInTransition(from, next string) bool {
+	return validator.In(next, walkInTransitions[from]...)
+}
+
+// generateTicket returns a short random code the walk-in can use to check
+// their place in the queue.
+func generateTicket() (string, error) {
+	randomBytes := make([]byte, 5)
+
+	_, err := rand.Read(randomBytes)
+	if err != nil {
+		return "", err
+	}
+
+	return base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(randomBytes), nil
+}
+
+// providerStaff returns the IDs of every staff member of the provider.
+func providerStaff(ctx context.Context, q queryer, providerID int64) ([]int64, error) {
+	query := `
+		SELECT id
+		FROM staff
+		WHERE provider_id = $1
+		ORDER BY id
+	`
+
+	rows, err := q.QueryContext(ctx, query, providerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var staffIDs []int64
+
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		staffIDs = append(staffIDs, id)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return staffIDs, nil
+}
+
+func (m WalkInModel) Insert(w *WalkIn) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := serviceDuration(ctx, m.DB, w.ProviderID, w.ServiceID)
+	if err != nil {
+		return err
+	}
+
+	if w.StaffID != nil {
+		_, err = offeringStaff(ctx, m.DB, w.ProviderID, w.ServiceID, *w.StaffID)
+		if err != nil {
+			return err
+		}
+	}
+
+	w.Ticket, err = generateTicket()
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO walk_ins (provider_id, service_id, staff_id, client_id, name, phone_number, ticket)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id, status, created_at
+	`
+
+	args := []any{
+		w.ProviderID,
+		w.ServiceID,
+		w.StaffID,
+		w.ClientID,
+		w.Name,
+		w.PhoneNumber,
+		w.Ticket,
+	}
+
+	err = m.DB.QueryRowContext(ctx, query, args...).Scan(&w.ID, &w.Status, &w.CreatedAt)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23503" && pgErr.ConstraintName == "walk_ins_client_id_fkey" {
+			return ErrClientNotFound
+		}
+		return err
+	}
+
+	return nil
+}
+
+func (m WalkInModel) Get(id, providerID int64) (*WalkIn, error) {
+	query := `
+		SELECT id, provider_id, service_id, staff_id, client_id, name, phone_number, ticket, status, created_at, started_at, finished_at
+		FROM walk_ins
+		WHERE id = $1 AND provider_id = $2
+	`
+
+	var w WalkIn
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, id, providerID).Scan(
+		&w.ID,
+		&w.ProviderID,
+		&w.ServiceID,
+		&w.StaffID,
+		&w.ClientID,
+		&w.Name,
+		&w.PhoneNumber,
+		&w.Ticket,
+		&w.Status,
+		&w.CreatedAt,
+		&w.StartedAt,
+		&w.FinishedAt,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &w, nil
+}
+
+// GetByTicket returns the walk-in holding the ticket, with its place in the
+// queue and estimated wait if it is still waiting.
+func (m WalkInModel) GetByTicket(ticket string) (*WalkIn, error) {
+	query := `
+		SELECT id, provider_id, service_id, staff_id, client_id, name, phone_number, ticket, status, created_at, started_at, finished_at
+		FROM walk_ins
+		WHERE ticket = $1
+	`
+
+	var w WalkIn
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, ticket).Scan(
+		&w.ID,
+		&w.ProviderID,
+		&w.ServiceID,
+		&w.StaffID,
+		&w.ClientID,
+		&w.Name,
+		&w.PhoneNumber,
+		&w.Ticket,
+		&w.Status,
+		&w.CreatedAt,
+		&w.StartedAt,
+		&w.FinishedAt,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	if w.Status != WalkInWaiting {
+		return &w, nil
+	}
+
+	queue, err := m.queue(ctx, w.ProviderID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, other := range queue {
+		if other.ID == w.ID {
+			return other, nil
+		}
+	}
+
+	return &w, nil
+}
+
+// GetQueue returns the provider's live queue: the walk-ins being served,
+// followed by those still waiting in order of arrival with their estimates.
+func (m WalkInModel) GetQueue(providerID int64) ([]*WalkIn, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return m.queue(ctx, providerID)
+}
+
+func (m WalkInModel) queue(ctx context.Context, providerID int64) ([]*WalkIn, error) {
+	query := `
+		SELECT id, provider_id, service_id, staff_id, client_id, name, phone_number, ticket, status, created_at, started_at, finished_at
+		FROM walk_ins
+		WHERE provider_id = $1 AND status IN ('serving', 'waiting')
+		ORDER BY status = 'waiting', created_at, id
+	`
+
+	rows, err := m.DB.QueryContext(ctx, query, providerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	queue := []*WalkIn{}
+
+	for rows.Next() {
+		var w WalkIn
+
+		err := rows.Scan(
+			&w.ID,
+			&w.ProviderID,
+			&w.ServiceID,
+			&w.StaffID,
+			&w.ClientID,
+			&w.Name,
+			&w.PhoneNumber,
+			&w.Ticket,
+			&w.Status,
+			&w.CreatedAt,
+			&w.StartedAt,
+			&w.FinishedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		queue = append(queue, &w)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	err = estimateQueue(ctx, m.DB, providerID, queue, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	return queue, nil
+}
+
+// estimateQueue numbers the waiting walk-ins and estimates when each of them
+// will be seen. Walk-ins are taken in order by whichever staff member working
+// today can fit the service in first around their appointments, time off and
+// the walk-ins they are already serving or are ahead in the queue. A walk-in
+// who cannot be fitted in before closing is left without an estimate. The
+// queue must list the walk-ins being served first.
+func estimateQueue(ctx context.Context, q queryer, providerID int64, queue []*WalkIn, now time.Time) error {
+	loc, err := providerLocation(ctx, q, providerID)
+	if err != nil {
+		return err
+	}
+
+	now = now.In(loc)
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+
+	staffIDs, err := providerStaff(ctx, q, providerID)
+	if err != nil {
+		return err
+	}
+
+	d, err := loadStaffDay(ctx, q, providerID, staffIDs, day)
+	if err != nil {
+		return err
+	}
+
+	timings := make(map[int64]timing)
+	offerers := make(map[int64][]int64)
+
+	position := 0
+
+	for _, w := range queue {
+		t, ok := timings[w.ServiceID]
+		if !ok {
+			t, err = serviceTiming(ctx, q, providerID, w.ServiceID)
+			if err != nil {
+				return err
+			}
+			timings[w.ServiceID] = t
+		}
+
+		if w.Status == WalkInServing {
+			block := t.block(*w.StartedAt)
+			block.End = maxTime(block.End, now)
+			d.busy[*w.StaffID] = append(d.busy[*w.StaffID], block)
+			continue
+		}
+
+		position++
+		w.Position = position
+
+		candidates, ok := offerers[w.ServiceID]
+		if !ok {
+			candidates, err = serviceStaff(ctx, q, providerID, w.ServiceID)
+			if err != nil {
+				return err
+			}
+			offerers[w.ServiceID] = candidates
+		}
+
+		if w.StaffID != nil {
+			candidates = []int64{*w.StaffID}
+		}
+
+		var (
+			best    time.Time
+			staffID int64
+		)
+
+		// The staff member can start preparing right away, so the service
+		// itself starts once the buffer before it has passed.
+		for _, id := range candidates {
+			blockStart, ok := earliestFit(d.working[id], d.busy[id], now, t.Before+t.Duration+t.After)
+			if start := blockStart.Add(t.Before); ok && (best.IsZero() || start.Before(best)) {
+				best, staffID = start, id
+			}
+		}
+
+		if best.IsZero() {
+			continue
+		}
+
+		d.busy[staffID] = append(d.busy[staffID], t.block(best))
+
+		wait := int(math.Ceil(best.Sub(now).Minutes()))
+		w.EstimatedStart = &best
+		w.EstimatedWaitMinutes = &wait
+	}
+
+	return nil
+}
+
+// UpdateStatus moves the walk-in to the given status. Starting to serve a
+// walk-in assigns it to the staff member, who must offer the service.
+func (m WalkInModel) UpdateStatus(w *WalkIn, to string, staffID *int64) error {
+	if !CanWalkInTransition(w.Status, to) {
+		return ErrInvalidTransition
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	if to == WalkInServing {
+		_, err := offeringStaff(ctx, m.DB, w.ProviderID, w.ServiceID, *staffID)
+		if err != nil {
+			return err
+		}
+		w.StaffID = staffID
+	}
+
+	query := `
+		UPDATE walk_ins
+		SET status = $1,
+			staff_id = $2,
+			started_at = CASE WHEN $1 = 'serving' THEN NOW() ELSE started_at END,
+			finished_at = CASE WHEN $1 IN ('served', 'left') THEN NOW() ELSE finished_at END
+		WHERE id = $3 AND status = $4
+		RETURNING started_at, finished_at
+	`
+
+	args := []any{to, w.StaffID, w.ID, w.Status}
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&w.StartedAt, &w.FinishedAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+
+	w.Status = to
+	return nil
+}
@@ -0,0 +1,466 @@
+package ical
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrNoCalendar       = errors.New("ical: no VCALENDAR object found")
+	ErrTooManyBusyTimes = errors.New("ical: too many busy times")
+	ErrTooComplex       = errors.New("ical: recurrence rules take too long to expand")
+)
+
+const (
+	dateFormat          = "20060102"
+	localDateTimeFormat = "20060102T150405"
+
+	// maxBusyTimes caps the number of busy times read from one calendar,
+	// recurring events included.
+	maxBusyTimes = 5000
+
+	// maxPeriods caps how many periods of recurrence rules are walked for
+	// one calendar, across all of its events, so that rules matching few or
+	// no days cannot tie up the server.
+	maxPeriods = 100000
+)
+
+// Busy is a period during which an imported calendar is busy.
+type Busy struct {
+	UID   string
+	Start time.Time
+	End   time.Time
+}
+
+type property struct {
+	name   string
+	params map[string]string
+	value  string
+}
+
+// event holds the properties of a VEVENT needed to work out when it is busy.
+type event struct {
+	uid          string
+	start        time.Time
+	allDay       bool
+	days         int
+	duration     time.Duration
+	hasEnd       bool
+	dtend        *property
+	rrule        string
+	exdates      []time.Time
+	recurrenceID *time.Time
+	cancelled    bool
+	transparent  bool
+}
+
+// ParseBusy reads an iCalendar object and returns the busy times of its events
+// that overlap the window from from to to, expanding recurring events.
+// Cancelled and transparent events are skipped, and so are instances of a
+// recurring event that were excluded or moved. Dates and floating times, as
+// well as times in an unknown time zone, are read in loc.
+func ParseBusy(r io.Reader, loc *time.Location, from, to time.Time) ([]Busy, error) {
+	events, err := readEvents(r, loc)
+	if err != nil {
+		return nil, err
+	}
+
+	// An instance that was moved or cancelled is sent as a separate event
+	// with the same UID, and the original instance must then be left out.
+	overridden := make(map[string][]time.Time)
+	for _, e := range events {
+		if e.recurrenceID != nil {
+			overridden[e.uid] = append(overridden[e.uid], *e.recurrenceID)
+		}
+	}
+
+	var busy []Busy
+
+	budget := maxPeriods
+
+	for _, e := range events {
+		if e.cancelled || e.transparent {
+			continue
+		}
+
+		if e.recurrenceID == nil {
+			e.exdates = append(e.exdates, overridden[e.uid]...)
+		}
+
+		starts, err := e.occurrences(from, to, &budget)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, start := range starts {
+			end := e.end(start)
+			if !end.After(start) {
+				continue
+			}
+
+			busy = append(busy, Busy{UID: e.uid, Start: start, End: end})
+			if len(busy) > maxBusyTimes {
+				return nil, ErrTooManyBusyTimes
+			}
+		}
+	}
+
+	slices.SortFunc(busy, func(a, b Busy) int {
+		return a.Start.Compare(b.Start)
+	})
+
+	return busy, nil
+}
+
+// readEvents unfolds the content lines of the calendar and collects the
+// properties of every VEVENT. Components nested inside an event, such as
+// alarms, are skipped.
+func readEvents(r io.Reader, loc *time.Location) ([]*event, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	var lines []string
+
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+
+		if len(line) > 0 && (line[0] == ' ' || line[0] == '\t') && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	var (
+		events   []*event
+		current  *event
+		stack    []string
+		calendar bool
+	)
+
+	for _, line := range lines {
+		p, err := parseProperty(line)
+		if err != nil {
+			return nil, err
+		}
+
+		switch p.name {
+		case "BEGIN":
+			component := strings.ToUpper(p.value)
+			stack = append(stack, component)
+
+			if component == "VCALENDAR" {
+				calendar = true
+			}
+			if component == "VEVENT" && len(stack) == 2 {
+				current = &event{}
+			}
+			continue
+
+		case "END":
+			if len(stack) == 0 || stack[len(stack)-1] != strings.ToUpper(p.value) {
+				return nil, fmt.Errorf("ical: unexpected END:%s", p.value)
+			}
+
+			if current != nil && len(stack) == 2 {
+				err = current.finish(loc)
+				if err != nil {
+					return nil, err
+				}
+				events = append(events, current)
+				current = nil
+			}
+
+			stack = stack[:len(stack)-1]
+			continue
+		}
+
+		if current == nil || len(stack) != 2 {
+			continue
+		}
+
+		err = current.set(p, loc)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if !calendar {
+		return nil, ErrNoCalendar
+	}
+
+	if len(stack) != 0 {
+		return nil, errors.New("ical: unterminated component")
+	}
+
+	return events, nil
+}
+
+func (e *event) set(p property, loc *time.Location) error {
+	switch p.name {
+	case "UID":
+		e.uid = p.value
+
+	case "DTSTART":
+		start, allDay, err := parseTime(p, loc)
+		if err != nil {
+			return err
+		}
+		e.start, e.allDay = start, allDay
+
+	case "DTEND":
+		// Properties may come in any order, so DTEND is resolved against
+		// DTSTART once the whole event has been read.
+		e.dtend = &p
+
+	case "DURATION":
+		days, d, err := parseDuration(p.value)
+		if err != nil {
+			return err
+		}
+		e.days, e.duration, e.hasEnd = days, d, true
+
+	case "RRULE":
+		e.rrule = p.value
+
+	case "EXDATE":
+		for _, value := range strings.Split(p.value, ",") {
+			t, _, err := parseTime(property{name: p.name, params: p.params, value: value}, loc)
+			if err != nil {
+				return err
+			}
+			e.exdates = append(e.exdates, t)
+		}
+
+	case "RECURRENCE-ID":
+		t, _, err := parseTime(p, loc)
+		if err != nil {
+			return err
+		}
+		e.recurrenceID = &t
+
+	case "STATUS":
+		e.cancelled = strings.EqualFold(p.value, "CANCELLED")
+
+	case "TRANSP":
+		e.transparent = strings.EqualFold(p.value, "TRANSPARENT")
+	}
+
+	return nil
+}
+
+// finish checks the event once all of its properties have been read and works
+// out how long each instance lasts. An event with neither DTEND nor DURATION
+// lasts a day if it starts on a date, and takes no time otherwise.
+func (e *event) finish(loc *time.Location) error {
+	if e.start.IsZero() {
+		return errors.New("ical: event without DTSTART")
+	}
+
+	switch {
+	case e.dtend != nil:
+		end, allDay, err := parseTime(*e.dtend, loc)
+		if err != nil {
+			return err
+		}
+		if allDay {
+			e.days = int(end.Sub(e.start).Round(24*time.Hour) / (24 * time.Hour))
+		} else {
+			e.duration = end.Sub(e.start)
+		}
+	case !e.hasEnd && e.allDay:
+		e.days = 1
+	}
+
+	return nil
+}
+
+// end returns when the instance of the event starting at start ends. Nominal
+// days are added on the calendar so that they stay whole across clock changes.
+func (e *event) end(start time.Time) time.Time {
+	return start.AddDate(0, 0, e.days).Add(e.duration)
+}
+
+// parseProperty splits a content line into its name, parameters and value.
+// Colons and semicolons inside quoted parameter values are not separators.
+func parseProperty(line string) (property, error) {
+	p := property{params: make(map[string]string)}
+
+	quoted := false
+	colon := -1
+
+	for i := 0; i < len(line); i++ {
+		switch line[i] {
+		case '"':
+			quoted = !quoted
+		case ':':
+			if !quoted {
+				colon = i
+			}
+		}
+		if colon >= 0 {
+			break
+		}
+	}
+
+	if colon < 0 {
+		return p, fmt.Errorf("ical: malformed content line %q", truncate(line))
+	}
+
+	p.value = line[colon+1:]
+
+	parts := splitUnquoted(line[:colon], ';')
+	p.name = strings.ToUpper(parts[0])
+
+	for _, param := range parts[1:] {
+		key, value, ok := strings.Cut(param, "=")
+		if !ok {
+			return p, fmt.Errorf("ical: malformed parameter %q", truncate(param))
+		}
+		p.params[strings.ToUpper(key)] = strings.Trim(value, `"`)
+	}
+
+	return p, nil
+}
+
+func splitUnquoted(s string, sep byte) []string {
+	var (
+		parts  []string
+		quoted bool
+		last   int
+	)
+
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '"':
+			quoted = !quoted
+		case s[i] == sep && !quoted:
+			parts = append(parts, s[last:i])
+			last = i + 1
+		}
+	}
+
+	return append(parts, s[last:])
+}
+
+func truncate(s string) string {
+	if len(s) > 40 {
+		return s[:40] + "..."
+	}
+	return s
+}
+
+// parseTime reads a DATE or DATE-TIME value. UTC times keep their zone, times
+// with a TZID are read in that zone, and dates and floating times are read in
+// loc. A TZID that is not a known IANA zone also falls back to loc.
+func parseTime(p property, loc *time.Location) (time.Time, bool, error) {
+	value := strings.TrimSpace(p.value)
+
+	if strings.EqualFold(p.params["VALUE"], "DATE") || len(value) == len(dateFormat) {
+		t, err := time.ParseInLocation(dateFormat, value, loc)
+		if err != nil {
+			return time.Time{}, false, fmt.Errorf("ical: invalid %s %q", p.name, truncate(value))
+		}
+		return t, true, nil
+	}
+
+	if strings.HasSuffix(value, "Z") {
+		t, err := time.Parse(dateTimeFormat, value)
+		if err != nil {
+			return time.Time{}, false, fmt.Errorf("ical: invalid %s %q", p.name, truncate(value))
+		}
+		return t, false, nil
+	}
+
+	zone := loc
+	if tzid := p.params["TZID"]; tzid != "" {
+		if l, err := time.LoadLocation(strings.TrimPrefix(tzid, "/")); err == nil {
+			zone = l
+		}
+	}
+
+	t, err := time.ParseInLocation(localDateTimeFormat, value, zone)
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("ical: invalid %s %q", p.name, truncate(value))
+	}
+
+	return t, false, nil
+}
+
+// parseDuration reads an RFC 5545 duration such as P1D, PT1H30M or P2W. Weeks
+// and days are returned as nominal days, the rest as an exact duration.
+func parseDuration(value string) (int, time.Duration, error) {
+	invalid := fmt.Errorf("ical: invalid DURATION %q", truncate(value))
+
+	s := strings.TrimPrefix(value, "+")
+	if strings.HasPrefix(s, "-") {
+		return 0, 0, invalid
+	}
+
+	s, ok := strings.CutPrefix(s, "P")
+	if !ok || s == "" {
+		return 0, 0, invalid
+	}
+
+	var (
+		days     int
+		duration time.Duration
+		inTime   bool
+		number   string
+	)
+
+	for _, c := range s {
+		switch {
+		case c >= '0' && c <= '9':
+			number += string(c)
+			continue
+		case c == 'T':
+			inTime = true
+			continue
+		}
+
+		if number == "" {
+			return 0, 0, invalid
+		}
+
+		n, err := strconv.Atoi(number)
+		if err != nil {
+			return 0, 0, invalid
+		}
+		number = ""
+
+		switch {
+		case c == 'W' && !inTime:
+			days += 7 * n
+		case c == 'D' && !inTime:
+			days += n
+		case c == 'H' && inTime:
+			duration += time.Duration(n) * time.Hour
+		case c == 'M' && inTime:
+			duration += time.Duration(n) * time.Minute
+		case c == 'S' && inTime:
+			duration += time.Duration(n) * time.Second
+		default:
+			return 0, 0, invalid
+		}
+	}
+
+	if number != "" {
+		return 0, 0, invalid
+	}
+
+	return days, duration, nil
+}
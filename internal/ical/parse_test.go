@@ -0,0 +1,467 @@
+package ical
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// calendar wraps the given lines in a VCALENDAR, joined with CRLF.
+func calendar(lines ...string) string {
+	lines = append([]string{"BEGIN:VCALENDAR", "VERSION:2.0"}, lines...)
+	lines = append(lines, "END:VCALENDAR")
+	return strings.Join(lines, "\r\n") + "\r\n"
+}
+
+// vevent wraps the given properties in a VEVENT.
+func vevent(props ...string) []string {
+	return append(append([]string{"BEGIN:VEVENT"}, props...), "END:VEVENT")
+}
+
+func mustLoad(t *testing.T, name string) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return loc
+}
+
+func TestParseBusy(t *testing.T) {
+	newYork := mustLoad(t, "America/New_York")
+	london := mustLoad(t, "Europe/London")
+
+	from := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	utc := func(s string) time.Time {
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			panic(err)
+		}
+		return t
+	}
+
+	type period struct{ start, end time.Time }
+
+	tests := []struct {
+		name  string
+		input string
+		loc   *time.Location
+		want  []period
+	}{
+		{
+			name: "utc date-time",
+			input: calendar(vevent(
+				"UID:1",
+				"DTSTART:20250310T090000Z",
+				"DTEND:20250310T100000Z",
+			)...),
+			want: []period{{utc("2025-03-10T09:00:00Z"), utc("2025-03-10T10:00:00Z")}},
+		},
+		{
+			name: "floating time read in the provider's zone",
+			input: calendar(vevent(
+				"UID:1",
+				"DTSTART:20250310T090000",
+				"DTEND:20250310T100000",
+			)...),
+			loc:  newYork,
+			want: []period{{utc("2025-03-10T13:00:00Z"), utc("2025-03-10T14:00:00Z")}},
+		},
+		{
+			name: "tzid",
+			input: calendar(vevent(
+				"UID:1",
+				"DTSTART;TZID=Europe/London:20250710T090000",
+				"DTEND;TZID=Europe/London:20250710T100000",
+			)...),
+			loc:  newYork,
+			want: []period{{utc("2025-07-10T08:00:00Z"), utc("2025-07-10T09:00:00Z")}},
+		},
+		{
+			name: "quoted tzid",
+			input: calendar(vevent(
+				"UID:1",
+				`DTSTART;TZID="Europe/London":20250110T090000`,
+				"DURATION:PT30M",
+			)...),
+			want: []period{{utc("2025-01-10T09:00:00Z"), utc("2025-01-10T09:30:00Z")}},
+		},
+		{
+			name: "unknown tzid falls back to the provider's zone",
+			input: calendar(vevent(
+				"UID:1",
+				"DTSTART;TZID=Custom Zone:20250110T090000",
+				"DTEND;TZID=Custom Zone:20250110T100000",
+			)...),
+			loc:  newYork,
+			want: []period{{utc("2025-01-10T14:00:00Z"), utc("2025-01-10T15:00:00Z")}},
+		},
+		{
+			name: "all-day date",
+			input: calendar(vevent(
+				"UID:1",
+				"DTSTART;VALUE=DATE:20250310",
+				"DTEND;VALUE=DATE:20250312",
+			)...),
+			loc:  newYork,
+			want: []period{{utc("2025-03-10T04:00:00Z"), utc("2025-03-12T04:00:00Z")}},
+		},
+		{
+			name: "all-day date without an end lasts a day",
+			input: calendar(vevent(
+				"UID:1",
+				"DTSTART;VALUE=DATE:20250310",
+			)...),
+			want: []period{{utc("2025-03-10T00:00:00Z"), utc("2025-03-11T00:00:00Z")}},
+		},
+		{
+			name: "all-day date spanning a clock change stays whole days",
+			input: calendar(vevent(
+				"UID:1",
+				"DTSTART;VALUE=DATE:20250308",
+				"DTEND;VALUE=DATE:20250310",
+			)...),
+			loc:  newYork,
+			want: []period{{utc("2025-03-08T05:00:00Z"), utc("2025-03-10T04:00:00Z")}},
+		},
+		{
+			name: "dtend before dtstart in the file",
+			input: calendar(vevent(
+				"UID:1",
+				"DTEND:20250310T100000Z",
+				"DTSTART:20250310T090000Z",
+			)...),
+			want: []period{{utc("2025-03-10T09:00:00Z"), utc("2025-03-10T10:00:00Z")}},
+		},
+		{
+			name: "folded lines",
+			input: calendar(vevent(
+				"UID:1",
+				"SUMMARY:A very long summary that goes on",
+				" and on across a folded line",
+				"DTSTART:2025031",
+				"\t0T090000Z",
+				"DTEND:20250310T100000Z",
+			)...),
+			want: []period{{utc("2025-03-10T09:00:00Z"), utc("2025-03-10T10:00:00Z")}},
+		},
+		{
+			name: "cancelled and transparent events are skipped",
+			input: calendar(append(append(vevent(
+				"UID:1",
+				"STATUS:CANCELLED",
+				"DTSTART:20250310T090000Z",
+				"DTEND:20250310T100000Z",
+			), vevent(
+				"UID:2",
+				"TRANSP:TRANSPARENT",
+				"DTSTART:20250311T090000Z",
+				"DTEND:20250311T100000Z",
+			)...), vevent(
+				"UID:3",
+				"DTSTART:20250312T090000Z",
+				"DTEND:20250312T100000Z",
+			)...)...),
+			want: []period{{utc("2025-03-12T09:00:00Z"), utc("2025-03-12T10:00:00Z")}},
+		},
+		{
+			name: "alarms inside events are ignored",
+			input: calendar(vevent(
+				"UID:1",
+				"DTSTART:20250310T090000Z",
+				"DTEND:20250310T100000Z",
+				"BEGIN:VALARM",
+				"TRIGGER:-PT15M",
+				"DTSTART:20250101T000000Z",
+				"END:VALARM",
+			)...),
+			want: []period{{utc("2025-03-10T09:00:00Z"), utc("2025-03-10T10:00:00Z")}},
+		},
+		{
+			name: "events outside the window are left out",
+			input: calendar(append(vevent(
+				"UID:1",
+				"DTSTART:20240310T090000Z",
+				"DTEND:20240310T100000Z",
+			), vevent(
+				"UID:2",
+				"DTSTART:20260310T090000Z",
+				"DTEND:20260310T100000Z",
+			)...)...),
+			want: nil,
+		},
+		{
+			name: "daily count",
+			input: calendar(vevent(
+				"UID:1",
+				"DTSTART:20250310T090000Z",
+				"DTEND:20250310T100000Z",
+				"RRULE:FREQ=DAILY;COUNT=3",
+			)...),
+			want: []period{
+				{utc("2025-03-10T09:00:00Z"), utc("2025-03-10T10:00:00Z")},
+				{utc("2025-03-11T09:00:00Z"), utc("2025-03-11T10:00:00Z")},
+				{utc("2025-03-12T09:00:00Z"), utc("2025-03-12T10:00:00Z")},
+			},
+		},
+		{
+			name: "weekly until is inclusive",
+			input: calendar(vevent(
+				"UID:1",
+				"DTSTART:20250303T090000Z",
+				"DTEND:20250303T100000Z",
+				"RRULE:FREQ=WEEKLY;BYDAY=MO,WE;UNTIL=20250310T090000Z",
+			)...),
+			want: []period{
+				{utc("2025-03-03T09:00:00Z"), utc("2025-03-03T10:00:00Z")},
+				{utc("2025-03-05T09:00:00Z"), utc("2025-03-05T10:00:00Z")},
+				{utc("2025-03-10T09:00:00Z"), utc("2025-03-10T10:00:00Z")},
+			},
+		},
+		{
+			name: "weekly keeps the wall clock across a clock change",
+			input: calendar(vevent(
+				"UID:1",
+				"DTSTART;TZID=America/New_York:20250301T090000",
+				"DTEND;TZID=America/New_York:20250301T100000",
+				"RRULE:FREQ=WEEKLY;COUNT=2",
+			)...),
+			want: []period{
+				{utc("2025-03-01T14:00:00Z"), utc("2025-03-01T15:00:00Z")},
+				{utc("2025-03-08T14:00:00Z"), utc("2025-03-08T15:00:00Z")},
+			},
+		},
+		{
+			name: "exdate",
+			input: calendar(vevent(
+				"UID:1",
+				"DTSTART;TZID=Europe/London:20250310T090000",
+				"DTEND;TZID=Europe/London:20250310T100000",
+				"RRULE:FREQ=DAILY;COUNT=3",
+				"EXDATE;TZID=Europe/London:20250311T090000",
+			)...),
+			want: []period{
+				{utc("2025-03-10T09:00:00Z"), utc("2025-03-10T10:00:00Z")},
+				{utc("2025-03-12T09:00:00Z"), utc("2025-03-12T10:00:00Z")},
+			},
+		},
+		{
+			name: "moved instance replaces the original",
+			input: calendar(append(vevent(
+				"UID:1",
+				"DTSTART:20250310T090000Z",
+				"DTEND:20250310T100000Z",
+				"RRULE:FREQ=DAILY;COUNT=2",
+			), vevent(
+				"UID:1",
+				"RECURRENCE-ID:20250311T090000Z",
+				"DTSTART:20250311T150000Z",
+				"DTEND:20250311T160000Z",
+			)...)...),
+			want: []period{
+				{utc("2025-03-10T09:00:00Z"), utc("2025-03-10T10:00:00Z")},
+				{utc("2025-03-11T15:00:00Z"), utc("2025-03-11T16:00:00Z")},
+			},
+		},
+		{
+			name: "monthly by ordinal weekday",
+			input: calendar(vevent(
+				"UID:1",
+				"DTSTART:20250114T090000Z",
+				"DTEND:20250114T100000Z",
+				"RRULE:FREQ=MONTHLY;BYDAY=2TU;COUNT=3",
+			)...),
+			want: []period{
+				{utc("2025-01-14T09:00:00Z"), utc("2025-01-14T10:00:00Z")},
+				{utc("2025-02-11T09:00:00Z"), utc("2025-02-11T10:00:00Z")},
+				{utc("2025-03-11T09:00:00Z"), utc("2025-03-11T10:00:00Z")},
+			},
+		},
+		{
+			name: "monthly skips months without the day",
+			input: calendar(vevent(
+				"UID:1",
+				"DTSTART:20250131T090000Z",
+				"DTEND:20250131T100000Z",
+				"RRULE:FREQ=MONTHLY;COUNT=3",
+			)...),
+			want: []period{
+				{utc("2025-01-31T09:00:00Z"), utc("2025-01-31T10:00:00Z")},
+				{utc("2025-03-31T09:00:00Z"), utc("2025-03-31T10:00:00Z")},
+				{utc("2025-05-31T09:00:00Z"), utc("2025-05-31T10:00:00Z")},
+			},
+		},
+		{
+			name: "rule without COUNT starting long before the window",
+			input: calendar(vevent(
+				"UID:1",
+				"DTSTART;TZID=America/New_York:19700105T090000",
+				"DTEND;TZID=America/New_York:19700105T100000",
+				"RRULE:FREQ=WEEKLY;INTERVAL=2;UNTIL=20250201T000000Z",
+			)...),
+			want: []period{
+				{utc("2025-01-06T14:00:00Z"), utc("2025-01-06T15:00:00Z")},
+				{utc("2025-01-20T14:00:00Z"), utc("2025-01-20T15:00:00Z")},
+			},
+		},
+		{
+			name: "instances before the window count towards COUNT",
+			input: calendar(vevent(
+				"UID:1",
+				"DTSTART:20241230T090000Z",
+				"DTEND:20241230T100000Z",
+				"RRULE:FREQ=DAILY;COUNT=4",
+			)...),
+			want: []period{
+				{utc("2025-01-01T09:00:00Z"), utc("2025-01-01T10:00:00Z")},
+				{utc("2025-01-02T09:00:00Z"), utc("2025-01-02T10:00:00Z")},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loc := tt.loc
+			if loc == nil {
+				loc = london
+			}
+
+			busy, err := ParseBusy(strings.NewReader(tt.input), loc, from, to)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(busy) != len(tt.want) {
+				t.Fatalf("got %d busy times %v, want %d", len(busy), busy, len(tt.want))
+			}
+
+			for i, b := range busy {
+				if !b.Start.Equal(tt.want[i].start) || !b.End.Equal(tt.want[i].end) {
+					t.Errorf("busy time %d is %s to %s, want %s to %s", i, b.Start.UTC(), b.End.UTC(), tt.want[i].start, tt.want[i].end)
+				}
+			}
+		})
+	}
+}
+
+func TestParseBusyErrors(t *testing.T) {
+	from := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2045, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		input   string
+		wantErr error
+	}{
+		{
+			name:  "not a calendar",
+			input: "hello world\r\n",
+		},
+		{
+			name:    "no calendar object",
+			input:   "BEGIN:VEVENT\r\nEND:VEVENT\r\n",
+			wantErr: ErrNoCalendar,
+		},
+		{
+			name:  "unterminated calendar",
+			input: "BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nDTSTART:20250310T090000Z\r\nEND:VEVENT\r\n",
+		},
+		{
+			name:  "mismatched end",
+			input: "BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nEND:VTODO\r\nEND:VCALENDAR\r\n",
+		},
+		{
+			name:  "event without dtstart",
+			input: calendar(vevent("UID:1", "DTEND:20250310T100000Z")...),
+		},
+		{
+			name:  "invalid date-time",
+			input: calendar(vevent("UID:1", "DTSTART:20251340T090000Z")...),
+		},
+		{
+			name:  "invalid duration",
+			input: calendar(vevent("UID:1", "DTSTART:20250310T090000Z", "DURATION:PT1X")...),
+		},
+		{
+			name:  "negative duration",
+			input: calendar(vevent("UID:1", "DTSTART:20250310T090000Z", "DURATION:-PT1H")...),
+		},
+		{
+			name:  "malformed parameter",
+			input: calendar(vevent("UID:1", "DTSTART;TZID:20250310T090000Z")...),
+		},
+		{
+			name:  "unsupported frequency",
+			input: calendar(vevent("UID:1", "DTSTART:20250310T090000Z", "RRULE:FREQ=HOURLY")...),
+		},
+		{
+			name:  "unsupported rule part",
+			input: calendar(vevent("UID:1", "DTSTART:20250310T090000Z", "RRULE:FREQ=DAILY;BYSETPOS=1")...),
+		},
+		{
+			name:  "ordinal weekday in a weekly rule",
+			input: calendar(vevent("UID:1", "DTSTART:20250310T090000Z", "RRULE:FREQ=WEEKLY;BYDAY=1MO")...),
+		},
+		{
+			name:  "zero interval",
+			input: calendar(vevent("UID:1", "DTSTART:20250310T090000Z", "RRULE:FREQ=DAILY;INTERVAL=0")...),
+		},
+		{
+			name:    "too many busy times",
+			input:   calendar(vevent("UID:1", "DTSTART:20250101T000000Z", "DURATION:PT1M", "RRULE:FREQ=DAILY;COUNT=6000")...),
+			wantErr: ErrTooManyBusyTimes,
+		},
+		{
+			name:    "counted rules that match nothing",
+			input:   calendar(vevent("UID:1", "DTSTART:10000101T090000Z", "DURATION:PT1H", "RRULE:FREQ=DAILY;BYMONTHDAY=31;BYMONTH=2;COUNT=2")...),
+			wantErr: ErrTooComplex,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseBusy(strings.NewReader(tt.input), time.UTC, from, to)
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("got error %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestParseBusyStopsWalkingRulesThatMatchNothing(t *testing.T) {
+	from := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 6, 0)
+
+	rules := []string{
+		"RRULE:FREQ=YEARLY;BYMONTH=2;BYMONTHDAY=30",
+		"RRULE:FREQ=MONTHLY;BYMONTH=4;BYMONTHDAY=31",
+		"RRULE:FREQ=DAILY;BYMONTH=2;BYMONTHDAY=31",
+		"RRULE:FREQ=DAILY;BYMONTHDAY=30;UNTIL=20000101T000000Z",
+	}
+
+	// Far more events than the whole calendar's budget would allow if each
+	// of them walked its rule to the end.
+	var lines []string
+	for i := 0; i < 2000; i++ {
+		lines = append(lines, vevent(
+			"UID:1",
+			"DTSTART:19900101T090000Z",
+			"DURATION:PT1H",
+			rules[i%len(rules)],
+		)...)
+	}
+
+	busy, err := ParseBusy(strings.NewReader(calendar(lines...)), time.UTC, from, to)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(busy) != 0 {
+		t.Errorf("got %d busy times, want none", len(busy))
+	}
+}
@@ -0,0 +1,473 @@
+package ical
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// rrule is a parsed recurrence rule. Only the parts needed for the repeating
+// events calendars usually hold are supported: FREQ from DAILY to YEARLY,
+// INTERVAL, COUNT, UNTIL, BYDAY, BYMONTHDAY, BYMONTH and WKST.
+type rrule struct {
+	freq       string
+	interval   int
+	count      int
+	until      *time.Time
+	byDay      []weekdayNum
+	byMonthDay []int
+	byMonth    []time.Month
+	weekStart  time.Weekday
+}
+
+// weekdayNum is a BYDAY entry such as MO, or 2TU for the second Tuesday.
+type weekdayNum struct {
+	n       int
+	weekday time.Weekday
+}
+
+var weekdays = map[string]time.Weekday{
+	"SU": time.Sunday,
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+}
+
+func parseRRule(value string, loc *time.Location) (*rrule, error) {
+	r := &rrule{interval: 1, weekStart: time.Monday}
+
+	for _, part := range strings.Split(value, ";") {
+		key, val, ok := strings.Cut(part, "=")
+		if !ok {
+			return nil, fmt.Errorf("ical: malformed RRULE part %q", truncate(part))
+		}
+
+		var err error
+
+		switch strings.ToUpper(key) {
+		case "FREQ":
+			r.freq = strings.ToUpper(val)
+		case "INTERVAL":
+			r.interval, err = strconv.Atoi(val)
+			if err == nil && r.interval < 1 {
+				err = fmt.Errorf("must be positive")
+			}
+		case "COUNT":
+			r.count, err = strconv.Atoi(val)
+			if err == nil && r.count < 1 {
+				err = fmt.Errorf("must be positive")
+			}
+		case "UNTIL":
+			var until time.Time
+			until, _, err = parseTime(property{name: "UNTIL", value: val}, loc)
+			r.until = &until
+		case "BYDAY":
+			for _, day := range strings.Split(val, ",") {
+				var wn weekdayNum
+				wn, err = parseWeekdayNum(day)
+				if err != nil {
+					break
+				}
+				r.byDay = append(r.byDay, wn)
+			}
+		case "BYMONTHDAY":
+			r.byMonthDay, err = parseInts(val, 1, 31)
+		case "BYMONTH":
+			var months []int
+			months, err = parseInts(val, 1, 12)
+			for _, m := range months {
+				if m < 0 {
+					err = fmt.Errorf("must be positive")
+				}
+				r.byMonth = append(r.byMonth, time.Month(m))
+			}
+		case "WKST":
+			day, known := weekdays[strings.ToUpper(val)]
+			if !known {
+				err = fmt.Errorf("unknown weekday")
+			}
+			r.weekStart = day
+		default:
+			return nil, fmt.Errorf("ical: unsupported RRULE part %s", strings.ToUpper(key))
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("ical: invalid RRULE %s %q", strings.ToUpper(key), truncate(val))
+		}
+	}
+
+	switch r.freq {
+	case "DAILY", "WEEKLY", "MONTHLY", "YEARLY":
+	default:
+		return nil, fmt.Errorf("ical: unsupported RRULE FREQ %q", truncate(r.freq))
+	}
+
+	// Days are only picked within a month, so rules that would pick them
+	// across a whole year or by day of the month within a week are rejected
+	// rather than expanded wrongly. The same goes for ordinal weekdays such as
+	// 1MO outside of a month.
+	unsupported := r.freq == "WEEKLY" && len(r.byMonthDay) > 0 ||
+		r.freq == "YEARLY" && len(r.byMonth) == 0 && (len(r.byDay) > 0 || len(r.byMonthDay) > 0)
+
+	for _, wn := range r.byDay {
+		if wn.n != 0 && (r.freq == "DAILY" || r.freq == "WEEKLY") {
+			unsupported = true
+		}
+	}
+
+	if unsupported {
+		return nil, fmt.Errorf("ical: unsupported RRULE %q", truncate(value))
+	}
+
+	return r, nil
+}
+
+func parseWeekdayNum(s string) (weekdayNum, error) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	if len(s) < 2 {
+		return weekdayNum{}, fmt.Errorf("invalid weekday")
+	}
+
+	day, ok := weekdays[s[len(s)-2:]]
+	if !ok {
+		return weekdayNum{}, fmt.Errorf("invalid weekday")
+	}
+
+	wn := weekdayNum{weekday: day}
+
+	if prefix := s[:len(s)-2]; prefix != "" {
+		n, err := strconv.Atoi(strings.TrimPrefix(prefix, "+"))
+		if err != nil || n == 0 || n < -5 || n > 5 {
+			return weekdayNum{}, fmt.Errorf("invalid weekday")
+		}
+		wn.n = n
+	}
+
+	return wn, nil
+}
+
+// parseInts reads a comma separated list of integers whose absolute values lie
+// between lo and hi.
+func parseInts(s string, lo, hi int) ([]int, error) {
+	var ints []int
+
+	for _, part := range strings.Split(s, ",") {
+		n, err := strconv.Atoi(strings.TrimPrefix(part, "+"))
+		if err != nil {
+			return nil, err
+		}
+		if abs(n) < lo || abs(n) > hi {
+			return nil, fmt.Errorf("out of range")
+		}
+		ints = append(ints, n)
+	}
+
+	return ints, nil
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// occurrences returns the start of every instance of the event that overlaps
+// the window from from to to. The first instance is always DTSTART, and
+// instances listed in EXDATE are left out. Each period of the rule walked
+// takes one from budget, which is shared by every event of the calendar, and
+// ErrTooComplex is returned once it runs out.
+func (e *event) occurrences(from, to time.Time, budget *int) ([]time.Time, error) {
+	within := func(start time.Time) bool {
+		return start.Before(to) && e.end(start).After(from) && !e.excluded(start)
+	}
+
+	if e.rrule == "" || e.recurrenceID != nil {
+		if !within(e.start) {
+			return nil, nil
+		}
+		return []time.Time{e.start}, nil
+	}
+
+	r, err := parseRRule(e.rrule, e.start.Location())
+	if err != nil {
+		return nil, err
+	}
+
+	starts := []time.Time{}
+	count := 1
+
+	if within(e.start) {
+		starts = append(starts, e.start)
+	}
+
+	// Without COUNT the instances before the window need not be counted, so
+	// the periods that end before it can start are skipped. A day is kept in
+	// hand for clock changes.
+	period := 0
+	if r.count == 0 {
+		period = r.periodBefore(e.start, from.AddDate(0, 0, -e.days-1).Add(-e.duration))
+	}
+
+	for ; ; period++ {
+		if *budget <= 0 {
+			return nil, ErrTooComplex
+		}
+		*budget--
+
+		// Nothing the period generates can start before the period does,
+		// so the walk stops there even if the rule matches no day at all.
+		first := r.periodStart(e.start, period)
+		if !first.Before(to) || r.until != nil && first.After(*r.until) {
+			return starts, nil
+		}
+
+		for _, start := range r.candidates(e.start, period) {
+			if !start.After(e.start) {
+				continue
+			}
+
+			if r.until != nil && start.After(*r.until) {
+				return starts, nil
+			}
+			if r.count > 0 && count >= r.count {
+				return starts, nil
+			}
+			if !start.Before(to) {
+				return starts, nil
+			}
+
+			count++
+
+			if within(start) {
+				starts = append(starts, start)
+				if len(starts) > maxBusyTimes {
+					return nil, ErrTooManyBusyTimes
+				}
+			}
+		}
+	}
+}
+
+func (e *event) excluded(t time.Time) bool {
+	for _, ex := range e.exdates {
+		if ex.Equal(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// periodStart returns midnight on the first day of the given period, in the
+// zone of dtstart. Every instance the period generates starts on or after it.
+func (r *rrule) periodStart(dtstart time.Time, period int) time.Time {
+	y, m, d := dtstart.Date()
+	step := period * r.interval
+
+	switch r.freq {
+	case "DAILY":
+		return date(y, m, d+step, dtstart)
+	case "WEEKLY":
+		offset := (int(dtstart.Weekday()) - int(r.weekStart) + 7) % 7
+		return date(y, m, d-offset+7*step, dtstart)
+	case "MONTHLY":
+		return date(y, m+time.Month(step), 1, dtstart)
+	default:
+		return date(y+step, time.January, 1, dtstart)
+	}
+}
+
+// periodBefore returns a period that starts no later than t, so that every
+// period before it generates only instances starting before t.
+func (r *rrule) periodBefore(dtstart, t time.Time) int {
+	if !t.After(dtstart) {
+		return 0
+	}
+
+	y1, m1, d1 := dtstart.Date()
+	y2, m2, d2 := t.In(dtstart.Location()).Date()
+
+	var units int
+
+	switch r.freq {
+	case "DAILY", "WEEKLY":
+		days := int(time.Date(y2, m2, d2, 0, 0, 0, 0, time.UTC).Sub(time.Date(y1, m1, d1, 0, 0, 0, 0, time.UTC)) / (24 * time.Hour))
+		units = days
+		if r.freq == "WEEKLY" {
+			units = days / 7
+		}
+	case "MONTHLY":
+		units = (y2-y1)*12 + int(m2-m1)
+	default:
+		units = y2 - y1
+	}
+
+	return max(units/r.interval-1, 0)
+}
+
+// candidates returns, in order, the instances the rule generates in the given
+// period after the one containing dtstart. Each keeps the wall clock time of
+// dtstart in its time zone.
+func (r *rrule) candidates(dtstart time.Time, period int) []time.Time {
+	y, m, d := dtstart.Date()
+	step := period * r.interval
+
+	var days []time.Time
+
+	switch r.freq {
+	case "DAILY":
+		days = []time.Time{r.periodStart(dtstart, period)}
+
+	case "WEEKLY":
+		weekStart := r.periodStart(dtstart, period)
+
+		wanted := r.byDay
+		if len(wanted) == 0 {
+			wanted = []weekdayNum{{weekday: dtstart.Weekday()}}
+		}
+
+		for i := 0; i < 7; i++ {
+			day := weekStart.AddDate(0, 0, i)
+			for _, wn := range wanted {
+				if day.Weekday() == wn.weekday {
+					days = append(days, day)
+				}
+			}
+		}
+
+	case "MONTHLY":
+		days = r.inMonth(r.periodStart(dtstart, period), d)
+
+	case "YEARLY":
+		months := r.byMonth
+		if len(months) == 0 {
+			months = []time.Month{m}
+		}
+
+		slices.Sort(months)
+
+		for _, month := range months {
+			days = append(days, r.inMonth(date(y+step, month, 1, dtstart), d)...)
+		}
+	}
+
+	var starts []time.Time
+
+	for _, day := range days {
+		if !r.matches(day) {
+			continue
+		}
+
+		h, min, s := dtstart.Clock()
+		starts = append(starts, time.Date(day.Year(), day.Month(), day.Day(), h, min, s, 0, dtstart.Location()))
+	}
+
+	slices.SortFunc(starts, time.Time.Compare)
+
+	return slices.CompactFunc(starts, time.Time.Equal)
+}
+
+// inMonth returns the days of the month starting at first picked by BYMONTHDAY
+// and BYDAY, or the day dtstart falls on when neither is given. Days that do
+// not exist in the month, such as the 31st of April, are skipped.
+func (r *rrule) inMonth(first time.Time, dtstartDay int) []time.Time {
+	last := first.AddDate(0, 1, -1).Day()
+
+	var byMonthDay []int
+	for _, n := range r.byMonthDay {
+		if n < 0 {
+			n = last + n + 1
+		}
+		if n >= 1 && n <= last {
+			byMonthDay = append(byMonthDay, n)
+		}
+	}
+
+	var byDay []int
+	for _, wn := range r.byDay {
+		for day := 1; day <= last; day++ {
+			if first.AddDate(0, 0, day-1).Weekday() != wn.weekday {
+				continue
+			}
+
+			nth := (day-1)/7 + 1
+			nthFromEnd := -((last-day)/7 + 1)
+
+			if wn.n == 0 || wn.n == nth || wn.n == nthFromEnd {
+				byDay = append(byDay, day)
+			}
+		}
+	}
+
+	var picked []int
+
+	switch {
+	case len(r.byMonthDay) > 0 && len(r.byDay) > 0:
+		for _, day := range byMonthDay {
+			if slices.Contains(byDay, day) {
+				picked = append(picked, day)
+			}
+		}
+	case len(r.byMonthDay) > 0:
+		picked = byMonthDay
+	case len(r.byDay) > 0:
+		picked = byDay
+	case dtstartDay <= last:
+		picked = []int{dtstartDay}
+	}
+
+	days := make([]time.Time, 0, len(picked))
+	for _, day := range picked {
+		days = append(days, first.AddDate(0, 0, day-1))
+	}
+
+	return days
+}
+
+// matches applies the parts of the rule that only narrow down the days
+// generated for a period.
+func (r *rrule) matches(day time.Time) bool {
+	if len(r.byMonth) > 0 && !slices.Contains(r.byMonth, day.Month()) {
+		return false
+	}
+
+	if r.freq == "DAILY" {
+		if len(r.byMonthDay) > 0 {
+			last := day.AddDate(0, 1, -day.Day()).Day()
+			ok := false
+			for _, n := range r.byMonthDay {
+				if n == day.Day() || last+n+1 == day.Day() {
+					ok = true
+				}
+			}
+			if !ok {
+				return false
+			}
+		}
+
+		if len(r.byDay) > 0 {
+			ok := false
+			for _, wn := range r.byDay {
+				if wn.weekday == day.Weekday() {
+					ok = true
+				}
+			}
+			if !ok {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// date normalises the given date, with days past the end of a month rolling
+// over, at midnight in the zone of ref.
+func date(y int, m time.Month, d int, ref time.Time) time.Time {
+	return time.Date(y, m, d, 0, 0, 0, 0, ref.Location())
+}
@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS idx_appointments_client_id;
+DROP INDEX IF EXISTS idx_appointments_staff_id;
+DROP INDEX IF EXISTS idx_appointments_provider_id;
+
+ALTER TABLE appointments DROP CONSTRAINT IF EXISTS appointments_staff_provider_fkey;
+ALTER TABLE appointments DROP CONSTRAINT IF EXISTS appointments_service_provider_fkey;
+ALTER TABLE appointments DROP COLUMN IF EXISTS provider_id;
@@ -0,0 +1,20 @@
+ALTER TABLE appointments ADD COLUMN provider_id INTEGER REFERENCES providers(id) ON DELETE CASCADE;
+
+UPDATE appointments a
+SET provider_id = s.provider_id
+FROM services s
+WHERE s.id = a.service_id;
+
+ALTER TABLE appointments ALTER COLUMN provider_id SET NOT NULL;
+
+ALTER TABLE appointments
+  ADD CONSTRAINT appointments_service_provider_fkey
+  FOREIGN KEY (service_id, provider_id) REFERENCES services(id, provider_id) ON DELETE CASCADE;
+
+ALTER TABLE appointments
+  ADD CONSTRAINT appointments_staff_provider_fkey
+  FOREIGN KEY (staff_id, provider_id) REFERENCES staff(id, provider_id) ON DELETE CASCADE;
+
+CREATE INDEX IF NOT EXISTS idx_appointments_provider_id ON appointments(provider_id);
+CREATE INDEX IF NOT EXISTS idx_appointments_staff_id ON appointments(staff_id);
+CREATE INDEX IF NOT EXISTS idx_appointments_client_id ON appointments(client_id);
@@ -0,0 +1,7 @@
+ALTER TABLE appointments DROP CONSTRAINT IF EXISTS appointments_no_overlap;
+ALTER TABLE appointments DROP CONSTRAINT IF EXISTS appointments_time_check;
+
+ALTER TABLE appointments DROP COLUMN IF EXISTS end_time;
+
+ALTER TABLE appointments ALTER COLUMN start_time TYPE TIMESTAMP USING start_time AT TIME ZONE 'UTC';
+ALTER TABLE appointments RENAME COLUMN start_time TO date;
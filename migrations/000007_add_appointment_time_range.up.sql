@@ -0,0 +1,25 @@
+CREATE EXTENSION IF NOT EXISTS btree_gist;
+
+ALTER TABLE appointments RENAME COLUMN date TO start_time;
+ALTER TABLE appointments ALTER COLUMN start_time TYPE timestamptz(0) USING start_time AT TIME ZONE 'UTC';
+
+ALTER TABLE appointments ADD COLUMN end_time timestamptz(0);
+
+UPDATE appointments a
+SET end_time = a.start_time + s.duration
+FROM services s
+WHERE s.id = a.service_id;
+
+ALTER TABLE appointments ALTER COLUMN end_time SET NOT NULL;
+
+ALTER TABLE appointments
+  ADD CONSTRAINT appointments_time_check CHECK (start_time < end_time);
+
+-- A staff member can only be in one place at a time. Cancelled appointments
+-- release their slot.
+ALTER TABLE appointments
+  ADD CONSTRAINT appointments_no_overlap
+  EXCLUDE USING gist (
+    staff_id WITH =,
+    tstzrange(start_time, end_time) WITH &&
+  ) WHERE (status <> 'cancelled');
@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS appointment_status_changes (
+  id INT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+  appointment_id INTEGER NOT NULL REFERENCES appointments(id) ON DELETE CASCADE,
+  from_status appointment_status NOT NULL,
+  to_status appointment_status NOT NULL,
+  changed_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+  reason TEXT,
+  changed_at timestamptz(0) NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_appointment_status_changes_appointment_id
+ON appointment_status_changes(appointment_id);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_appointment_reschedules_appointment_id;
+DROP TABLE IF EXISTS appointment_reschedules;
@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS appointment_reschedules (
+  id INT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+  appointment_id INTEGER NOT NULL REFERENCES appointments(id) ON DELETE CASCADE,
+  previous_staff_id INTEGER NOT NULL REFERENCES staff(id) ON DELETE CASCADE,
+  previous_start_time timestamptz(0) NOT NULL,
+  previous_end_time timestamptz(0) NOT NULL,
+  rescheduled_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+  rescheduled_at timestamptz(0) NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_appointment_reschedules_appointment_id
+ON appointment_reschedules(appointment_id);
@@ -0,0 +1,3 @@
+ALTER TABLE providers
+  DROP COLUMN IF EXISTS max_reschedules,
+  DROP COLUMN IF EXISTS cancellation_notice_hours;
@@ -0,0 +1,3 @@
+ALTER TABLE providers
+  ADD COLUMN cancellation_notice_hours INTEGER CHECK (cancellation_notice_hours >= 0),
+  ADD COLUMN max_reschedules INTEGER CHECK (max_reschedules >= 0);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_staff_time_off_staff_id;
+DROP TABLE IF EXISTS staff_time_off;
+DROP TABLE IF EXISTS staff_schedules;
@@ -0,0 +1,25 @@
+CREATE TABLE IF NOT EXISTS staff_schedules (
+  id INT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+  staff_id INTEGER NOT NULL,
+  provider_id INTEGER NOT NULL,
+  day_of_week SMALLINT NOT NULL CHECK (day_of_week BETWEEN 0 AND 6), -- 0=Sunday, 6=Saturday
+  start_time TIME NOT NULL,
+  end_time TIME NOT NULL,
+  CHECK (start_time < end_time),
+  UNIQUE (staff_id, day_of_week),
+  FOREIGN KEY (staff_id, provider_id) REFERENCES staff(id, provider_id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS staff_time_off (
+  id INT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+  staff_id INTEGER NOT NULL,
+  provider_id INTEGER NOT NULL,
+  start_time timestamptz(0) NOT NULL,
+  end_time timestamptz(0) NOT NULL,
+  reason TEXT,
+  created_at timestamptz(0) NOT NULL DEFAULT NOW(),
+  CHECK (start_time < end_time),
+  FOREIGN KEY (staff_id, provider_id) REFERENCES staff(id, provider_id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_staff_time_off_staff_id ON staff_time_off(staff_id);
@@ -0,0 +1,20 @@
+CREATE TABLE IF NOT EXISTS provider_hour_exceptions (
+  id INT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+  provider_id INTEGER NOT NULL REFERENCES providers(id) ON DELETE CASCADE,
+  start_date DATE NOT NULL,
+  end_date DATE NOT NULL,
+  is_closed BOOLEAN NOT NULL DEFAULT FALSE,
+  open_time TIME,
+  close_time TIME,
+  reason TEXT,
+  created_at timestamptz(0) NOT NULL DEFAULT NOW(),
+  CHECK (start_date <= end_date),
+  CHECK (
+    (is_closed = TRUE AND open_time IS NULL AND close_time IS NULL) OR
+    (is_closed = FALSE AND open_time IS NOT NULL AND close_time IS NOT NULL AND open_time < close_time)
+  ),
+  CONSTRAINT provider_hour_exceptions_no_overlap EXCLUDE USING gist (
+    provider_id WITH =,
+    daterange(start_date, end_date, '[]') WITH &&
+  )
+);
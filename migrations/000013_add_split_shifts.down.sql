@@ -0,0 +1,13 @@
+ALTER TABLE staff_schedules
+  DROP CONSTRAINT IF EXISTS staff_schedules_no_overlap;
+
+ALTER TABLE staff_schedules
+  ADD CONSTRAINT staff_schedules_staff_id_day_of_week_key UNIQUE (staff_id, day_of_week);
+
+DROP INDEX IF EXISTS idx_provider_business_hours_closed;
+
+ALTER TABLE provider_business_hours
+  DROP CONSTRAINT IF EXISTS provider_business_hours_no_overlap;
+
+ALTER TABLE provider_business_hours
+  ADD CONSTRAINT provider_business_hours_provider_id_day_of_week_key UNIQUE (provider_id, day_of_week);
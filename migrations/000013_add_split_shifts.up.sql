@@ -0,0 +1,22 @@
+ALTER TABLE provider_business_hours
+  DROP CONSTRAINT IF EXISTS provider_business_hours_provider_id_day_of_week_key;
+
+ALTER TABLE provider_business_hours
+  ADD CONSTRAINT provider_business_hours_no_overlap EXCLUDE USING gist (
+    provider_id WITH =,
+    day_of_week WITH =,
+    tsrange('2000-01-01'::date + open_time, '2000-01-01'::date + close_time) WITH &&
+  ) WHERE (NOT is_closed);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_provider_business_hours_closed
+  ON provider_business_hours(provider_id, day_of_week) WHERE is_closed;
+
+ALTER TABLE staff_schedules
+  DROP CONSTRAINT IF EXISTS staff_schedules_staff_id_day_of_week_key;
+
+ALTER TABLE staff_schedules
+  ADD CONSTRAINT staff_schedules_no_overlap EXCLUDE USING gist (
+    staff_id WITH =,
+    day_of_week WITH =,
+    tsrange('2000-01-01'::date + start_time, '2000-01-01'::date + end_time) WITH &&
+  );
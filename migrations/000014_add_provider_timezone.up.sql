@@ -0,0 +1,2 @@
+ALTER TABLE providers
+  ADD COLUMN IF NOT EXISTS timezone TEXT NOT NULL DEFAULT 'UTC';
@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS idx_appointments_series_id;
+
+ALTER TABLE appointments
+  DROP COLUMN IF EXISTS series_id;
+
+DROP INDEX IF EXISTS idx_appointment_series_client_id;
+DROP TABLE IF EXISTS appointment_series;
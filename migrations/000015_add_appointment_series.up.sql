@@ -0,0 +1,22 @@
+CREATE TABLE IF NOT EXISTS appointment_series (
+  id INT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+  provider_id INTEGER NOT NULL REFERENCES providers(id) ON DELETE CASCADE,
+  service_id INTEGER NOT NULL,
+  staff_id INTEGER NOT NULL,
+  client_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  frequency TEXT NOT NULL CHECK (frequency IN ('weekly', 'biweekly', 'monthly')),
+  occurrence_count INTEGER CHECK (occurrence_count > 0),
+  until_date DATE,
+  start_time timestamptz(0) NOT NULL,
+  created_at timestamptz(0) NOT NULL DEFAULT NOW(),
+  CHECK ((occurrence_count IS NULL) <> (until_date IS NULL)),
+  FOREIGN KEY (service_id, provider_id) REFERENCES services(id, provider_id) ON DELETE CASCADE,
+  FOREIGN KEY (staff_id, provider_id) REFERENCES staff(id, provider_id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_appointment_series_client_id ON appointment_series(client_id);
+
+ALTER TABLE appointments
+  ADD COLUMN IF NOT EXISTS series_id INTEGER REFERENCES appointment_series(id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS idx_appointments_series_id ON appointments(series_id);
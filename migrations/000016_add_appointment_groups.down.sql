@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS idx_appointments_group_id;
+
+ALTER TABLE appointments
+  DROP COLUMN IF EXISTS group_id;
+
+DROP INDEX IF EXISTS idx_appointment_groups_client_id;
+DROP TABLE IF EXISTS appointment_groups;
@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS appointment_groups (
+  id INT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+  provider_id INTEGER NOT NULL REFERENCES providers(id) ON DELETE CASCADE,
+  client_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  start_time timestamptz(0) NOT NULL,
+  end_time timestamptz(0) NOT NULL,
+  total_price NUMERIC(10, 2) NOT NULL CHECK (total_price >= 0),
+  created_at timestamptz(0) NOT NULL DEFAULT NOW(),
+  CHECK (start_time < end_time)
+);
+
+CREATE INDEX IF NOT EXISTS idx_appointment_groups_client_id ON appointment_groups(client_id);
+
+ALTER TABLE appointments
+  ADD COLUMN IF NOT EXISTS group_id INTEGER REFERENCES appointment_groups(id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS idx_appointments_group_id ON appointments(group_id);
@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS idx_waitlist_offers_staff_id;
+DROP INDEX IF EXISTS idx_waitlist_offers_entry_id;
+DROP TABLE IF EXISTS waitlist_offers;
+
+DROP INDEX IF EXISTS idx_waitlist_entries_provider_id;
+DROP INDEX IF EXISTS idx_waitlist_entries_client_id;
+DROP TABLE IF EXISTS waitlist_entries;
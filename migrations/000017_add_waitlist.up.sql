@@ -0,0 +1,33 @@
+CREATE TABLE IF NOT EXISTS waitlist_entries (
+  id INT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+  provider_id INTEGER NOT NULL REFERENCES providers(id) ON DELETE CASCADE,
+  service_id INTEGER NOT NULL,
+  staff_id INTEGER,
+  client_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  start_date DATE NOT NULL,
+  end_date DATE NOT NULL,
+  status TEXT NOT NULL DEFAULT 'waiting' CHECK (status IN ('waiting', 'booked')),
+  created_at timestamptz(0) NOT NULL DEFAULT NOW(),
+  CHECK (start_date <= end_date),
+  FOREIGN KEY (service_id, provider_id) REFERENCES services(id, provider_id) ON DELETE CASCADE,
+  FOREIGN KEY (staff_id, provider_id) REFERENCES staff(id, provider_id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_waitlist_entries_client_id ON waitlist_entries(client_id);
+CREATE INDEX IF NOT EXISTS idx_waitlist_entries_provider_id ON waitlist_entries(provider_id, created_at) WHERE status = 'waiting';
+
+CREATE TABLE IF NOT EXISTS waitlist_offers (
+  id INT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+  entry_id INTEGER NOT NULL REFERENCES waitlist_entries(id) ON DELETE CASCADE,
+  staff_id INTEGER NOT NULL REFERENCES staff(id) ON DELETE CASCADE,
+  start_time timestamptz(0) NOT NULL,
+  end_time timestamptz(0) NOT NULL,
+  token_hash bytea NOT NULL UNIQUE,
+  expiry timestamptz(0) NOT NULL,
+  claimed_at timestamptz(0),
+  created_at timestamptz(0) NOT NULL DEFAULT NOW(),
+  CHECK (start_time < end_time)
+);
+
+CREATE INDEX IF NOT EXISTS idx_waitlist_offers_entry_id ON waitlist_offers(entry_id);
+CREATE INDEX IF NOT EXISTS idx_waitlist_offers_staff_id ON waitlist_offers(staff_id, start_time) WHERE claimed_at IS NULL;
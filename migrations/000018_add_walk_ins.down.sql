@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_walk_ins_queue;
+DROP TABLE IF EXISTS walk_ins;
@@ -0,0 +1,20 @@
+CREATE TABLE IF NOT EXISTS walk_ins (
+  id INT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+  provider_id INTEGER NOT NULL REFERENCES providers(id) ON DELETE CASCADE,
+  service_id INTEGER NOT NULL,
+  staff_id INTEGER,
+  client_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
+  name TEXT,
+  phone_number TEXT,
+  ticket TEXT NOT NULL UNIQUE,
+  status TEXT NOT NULL DEFAULT 'waiting' CHECK (status IN ('waiting', 'serving', 'served', 'left')),
+  created_at timestamptz(0) NOT NULL DEFAULT NOW(),
+  started_at timestamptz(0),
+  finished_at timestamptz(0),
+  CHECK (client_id IS NOT NULL OR (name IS NOT NULL AND phone_number IS NOT NULL)),
+  CHECK (status <> 'serving' OR (staff_id IS NOT NULL AND started_at IS NOT NULL)),
+  FOREIGN KEY (service_id, provider_id) REFERENCES services(id, provider_id) ON DELETE CASCADE,
+  FOREIGN KEY (staff_id, provider_id) REFERENCES staff(id, provider_id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_walk_ins_queue ON walk_ins(provider_id, created_at) WHERE status IN ('waiting', 'serving');
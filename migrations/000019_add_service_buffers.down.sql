@@ -0,0 +1,17 @@
+ALTER TABLE appointments DROP CONSTRAINT IF EXISTS appointments_no_overlap;
+
+ALTER TABLE appointments
+  ADD CONSTRAINT appointments_no_overlap
+  EXCLUDE USING gist (
+    staff_id WITH =,
+    tstzrange(start_time, end_time) WITH &&
+  ) WHERE (status <> 'cancelled');
+
+ALTER TABLE appointments
+  DROP CONSTRAINT IF EXISTS appointments_blocked_check,
+  DROP COLUMN IF EXISTS blocked_end,
+  DROP COLUMN IF EXISTS blocked_start;
+
+ALTER TABLE services
+  DROP COLUMN IF EXISTS buffer_after,
+  DROP COLUMN IF EXISTS buffer_before;
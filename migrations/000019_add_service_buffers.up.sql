@@ -0,0 +1,26 @@
+ALTER TABLE services
+  ADD COLUMN IF NOT EXISTS buffer_before INTERVAL NOT NULL DEFAULT INTERVAL '0' CHECK (buffer_before >= INTERVAL '0'),
+  ADD COLUMN IF NOT EXISTS buffer_after INTERVAL NOT NULL DEFAULT INTERVAL '0' CHECK (buffer_after >= INTERVAL '0');
+
+-- The time an appointment takes on the staff member's calendar, including the
+-- service's buffers as they were when it was booked.
+ALTER TABLE appointments
+  ADD COLUMN IF NOT EXISTS blocked_start timestamptz(0),
+  ADD COLUMN IF NOT EXISTS blocked_end timestamptz(0);
+
+UPDATE appointments
+SET blocked_start = start_time, blocked_end = end_time;
+
+ALTER TABLE appointments
+  ALTER COLUMN blocked_start SET NOT NULL,
+  ALTER COLUMN blocked_end SET NOT NULL,
+  ADD CONSTRAINT appointments_blocked_check CHECK (blocked_start <= start_time AND blocked_end >= end_time);
+
+ALTER TABLE appointments DROP CONSTRAINT IF EXISTS appointments_no_overlap;
+
+ALTER TABLE appointments
+  ADD CONSTRAINT appointments_no_overlap
+  EXCLUDE USING gist (
+    staff_id WITH =,
+    tstzrange(blocked_start, blocked_end) WITH &&
+  ) WHERE (status <> 'cancelled');
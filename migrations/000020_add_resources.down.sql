@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_appointment_resources_resource_id;
+DROP TABLE IF EXISTS appointment_resources;
+DROP TABLE IF EXISTS service_resources;
+DROP TABLE IF EXISTS resources;
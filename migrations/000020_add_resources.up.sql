@@ -0,0 +1,26 @@
+CREATE TABLE IF NOT EXISTS resources (
+  id INT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+  provider_id INTEGER NOT NULL REFERENCES providers(id) ON DELETE CASCADE,
+  name TEXT NOT NULL,
+  capacity INTEGER NOT NULL CHECK (capacity > 0),
+  created_at timestamptz(0) NOT NULL DEFAULT NOW(),
+  UNIQUE (provider_id, name),
+  UNIQUE (provider_id, id)
+);
+
+CREATE TABLE IF NOT EXISTS service_resources (
+  service_id INTEGER NOT NULL,
+  resource_id INTEGER NOT NULL,
+  provider_id INTEGER NOT NULL,
+  PRIMARY KEY (service_id, resource_id),
+  FOREIGN KEY (service_id, provider_id) REFERENCES services(id, provider_id) ON DELETE CASCADE,
+  FOREIGN KEY (resource_id, provider_id) REFERENCES resources(id, provider_id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS appointment_resources (
+  appointment_id INTEGER NOT NULL REFERENCES appointments(id) ON DELETE CASCADE,
+  resource_id INTEGER NOT NULL REFERENCES resources(id) ON DELETE CASCADE,
+  PRIMARY KEY (appointment_id, resource_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_appointment_resources_resource_id ON appointment_resources(resource_id);
@@ -0,0 +1,16 @@
+ALTER TABLE appointments DROP CONSTRAINT IF EXISTS appointments_no_overlap;
+
+ALTER TABLE appointments
+  ADD CONSTRAINT appointments_no_overlap
+  EXCLUDE USING gist (
+    staff_id WITH =,
+    tstzrange(blocked_start, blocked_end) WITH &&
+  ) WHERE (status <> 'cancelled');
+
+DROP INDEX IF EXISTS idx_appointments_session_key;
+
+ALTER TABLE appointments
+  DROP COLUMN IF EXISTS session_key;
+
+ALTER TABLE services
+  DROP COLUMN IF EXISTS capacity;
@@ -0,0 +1,20 @@
+ALTER TABLE services
+  ADD COLUMN IF NOT EXISTS capacity INTEGER NOT NULL DEFAULT 1 CHECK (capacity > 0);
+
+-- Appointments for the same session of a class share a key, which lets them
+-- overlap on the staff member's calendar. Every other appointment is keyed by
+-- its own ID.
+ALTER TABLE appointments
+  ADD COLUMN IF NOT EXISTS session_key TEXT;
+
+CREATE INDEX IF NOT EXISTS idx_appointments_session_key ON appointments(session_key) WHERE session_key IS NOT NULL;
+
+ALTER TABLE appointments DROP CONSTRAINT IF EXISTS appointments_no_overlap;
+
+ALTER TABLE appointments
+  ADD CONSTRAINT appointments_no_overlap
+  EXCLUDE USING gist (
+    staff_id WITH =,
+    tstzrange(blocked_start, blocked_end) WITH &&,
+    COALESCE(session_key, id::text) WITH <>
+  ) WHERE (status <> 'cancelled');
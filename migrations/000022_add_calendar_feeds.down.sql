@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_tokens_user_id_scope;
+
+ALTER TABLE tokens
+  DROP COLUMN IF EXISTS staff_id;
@@ -0,0 +1,6 @@
+-- Calendar feed tokens created by a provider for one of their staff members
+-- render that staff member's appointments rather than the provider's.
+ALTER TABLE tokens
+  ADD COLUMN IF NOT EXISTS staff_id INTEGER REFERENCES staff(id) ON DELETE CASCADE;
+
+CREATE INDEX IF NOT EXISTS idx_tokens_user_id_scope ON tokens(user_id, scope);
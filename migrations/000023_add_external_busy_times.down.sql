@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_external_busy_times_source_id;
+DROP INDEX IF EXISTS idx_external_busy_times_staff_id;
+DROP TABLE IF EXISTS external_busy_times;
+DROP TABLE IF EXISTS calendar_sources;
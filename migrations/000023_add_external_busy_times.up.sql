@@ -0,0 +1,31 @@
+-- A calendar source is an external calendar, reached by URL, that a staff
+-- member's busy times are imported from.
+CREATE TABLE IF NOT EXISTS calendar_sources (
+  id INT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+  staff_id INTEGER NOT NULL,
+  provider_id INTEGER NOT NULL,
+  url TEXT NOT NULL,
+  last_synced_at timestamptz(0),
+  last_error TEXT,
+  created_at timestamptz(0) NOT NULL DEFAULT NOW(),
+  UNIQUE (staff_id, url),
+  FOREIGN KEY (staff_id, provider_id) REFERENCES staff(id, provider_id) ON DELETE CASCADE
+);
+
+-- Busy times imported from an uploaded file have no source. Each import
+-- replaces the busy times of the previous one.
+CREATE TABLE IF NOT EXISTS external_busy_times (
+  id INT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+  staff_id INTEGER NOT NULL,
+  provider_id INTEGER NOT NULL,
+  source_id INTEGER REFERENCES calendar_sources(id) ON DELETE CASCADE,
+  uid TEXT NOT NULL DEFAULT '',
+  start_time timestamptz(0) NOT NULL,
+  end_time timestamptz(0) NOT NULL,
+  created_at timestamptz(0) NOT NULL DEFAULT NOW(),
+  CHECK (start_time < end_time),
+  FOREIGN KEY (staff_id, provider_id) REFERENCES staff(id, provider_id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_external_busy_times_staff_id ON external_busy_times(staff_id, start_time);
+CREATE INDEX IF NOT EXISTS idx_external_busy_times_source_id ON external_busy_times(source_id);
@@ -0,0 +1,8 @@
+-- Postgres cannot drop a value from an enum, so 'held' stays on the type once
+-- the holds themselves are gone.
+DELETE FROM appointments WHERE hold_expires_at IS NOT NULL;
+
+DROP INDEX IF EXISTS idx_appointments_hold_expires_at;
+
+ALTER TABLE appointments
+  DROP COLUMN IF EXISTS hold_expires_at;
@@ -0,0 +1,9 @@
+-- A held appointment reserves its slot while the client checks out. The new
+-- value cannot be used in the same transaction that adds it, so the index
+-- below is keyed on hold_expires_at rather than on the status.
+ALTER TYPE appointment_status ADD VALUE IF NOT EXISTS 'held';
+
+ALTER TABLE appointments
+  ADD COLUMN IF NOT EXISTS hold_expires_at timestamptz(0);
+
+CREATE INDEX IF NOT EXISTS idx_appointments_hold_expires_at ON appointments(hold_expires_at) WHERE hold_expires_at IS NOT NULL;
@@ -0,0 +1,7 @@
+-- Authentication tokens double as sessions, which are listed and revoked by
+-- ID so that their hashes never leave the server.
+ALTER TABLE tokens
+  ADD COLUMN IF NOT EXISTS id BIGINT GENERATED ALWAYS AS IDENTITY UNIQUE,
+  ADD COLUMN IF NOT EXISTS created_at timestamptz(0) NOT NULL DEFAULT NOW(),
+  ADD COLUMN IF NOT EXISTS last_used_at timestamptz(0),
+  ADD COLUMN IF NOT EXISTS user_agent TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,9 @@
+DELETE FROM tokens WHERE scope = 'refresh';
+
+DROP INDEX IF EXISTS idx_tokens_family_id;
+
+ALTER TABLE tokens
+  DROP COLUMN IF EXISTS used_at,
+  DROP COLUMN IF EXISTS family_id;
+
+DROP SEQUENCE IF EXISTS tokens_family_id_seq;
@@ -0,0 +1,10 @@
+-- The access and refresh tokens issued at login, and every pair rotated from
+-- them, share a family, which is revoked as a whole when a used refresh token
+-- is presented again.
+CREATE SEQUENCE IF NOT EXISTS tokens_family_id_seq;
+
+ALTER TABLE tokens
+  ADD COLUMN IF NOT EXISTS family_id BIGINT,
+  ADD COLUMN IF NOT EXISTS used_at timestamptz(0);
+
+CREATE INDEX IF NOT EXISTS idx_tokens_family_id ON tokens(family_id) WHERE family_id IS NOT NULL;
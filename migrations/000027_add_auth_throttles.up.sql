@@ -0,0 +1,13 @@
+-- Failed attempts at logging in and at entering emailed codes are counted per
+-- account and per IP address, so that lockouts hold across instances.
+CREATE TABLE IF NOT EXISTS auth_throttles (
+  action TEXT NOT NULL,
+  kind TEXT NOT NULL,
+  value TEXT NOT NULL,
+  failures INTEGER NOT NULL DEFAULT 0,
+  locked_until timestamptz(0),
+  last_failure_at timestamptz(0) NOT NULL DEFAULT NOW(),
+  PRIMARY KEY (action, kind, value)
+);
+
+CREATE INDEX IF NOT EXISTS idx_auth_throttles_last_failure_at ON auth_throttles(last_failure_at);